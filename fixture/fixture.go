@@ -0,0 +1,106 @@
+// Package fixture loads proto messages used by port Send and Receive
+// from textproto or JSON fixture files.
+package fixture
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+
+	"github.com/smallinsky/mtf/match"
+)
+
+type options struct {
+	vars map[string]interface{}
+}
+
+type Option func(*options)
+
+// WithVar sets template variable name used as {{.name}} in fixture file.
+func WithVar(name string, value interface{}) Option {
+	return func(o *options) {
+		o.vars[name] = value
+	}
+}
+
+// WithVars sets variables captured by match.Vars as template variables.
+func WithVars(vars *match.Vars) Option {
+	return func(o *options) {
+		for k, v := range vars.Values() {
+			o.vars[k] = v
+		}
+	}
+}
+
+// funcs escape variables substituted into fixture files, json renders
+// value as JSON and quote as textproto string literal.
+var funcs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		buf, err := json.Marshal(v)
+		return string(buf), err
+	},
+	"quote": func(v interface{}) string {
+		return strconv.Quote(fmt.Sprint(v))
+	},
+}
+
+// Load reads fixture file at path into msg and returns it, test fails
+// if the fixture can't be loaded.
+func Load(t *testing.T, path string, msg proto.Message, opts ...Option) proto.Message {
+	t.Helper()
+	if err := Read(path, msg, opts...); err != nil {
+		t.Fatalf("failed to load fixture: %v", err)
+	}
+	return msg
+}
+
+// Read reads fixture file at path into msg. Files with .json extension
+// are decoded as proto JSON, other files as textproto. Fixture is
+// executed as text/template with variables set by options before
+// decoding, missing variables are reported as error. Variables are not
+// escaped, use {{json .name}} in JSON and {{quote .name}} in textproto
+// fixtures.
+func Read(path string, msg proto.Message, opts ...Option) error {
+	options := options{
+		vars: make(map[string]interface{}),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read fixture file")
+	}
+	tmpl, err := template.New(filepath.Base(path)).
+		Option("missingkey=error").
+		Funcs(funcs).
+		Parse(string(buf))
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse fixture template %s", path)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, options.vars); err != nil {
+		return errors.Wrapf(err, "failed to execute fixture template %s", path)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = jsonpb.Unmarshal(&out, msg)
+	} else {
+		err = proto.UnmarshalText(out.String(), msg)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to decode fixture %s into %T", path, msg)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package fixture
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/golang/protobuf/proto/proto3_proto"
+
+	"github.com/smallinsky/mtf/match"
+)
+
+func TestRead(t *testing.T) {
+	vars := match.NewVars()
+	vars.Set("name", "captured")
+
+	cases := []struct {
+		name string
+		path string
+		opts []Option
+		exp  *pb.Message
+		err  string
+	}{
+		{
+			name: "textproto",
+			path: "testdata/message.textproto",
+			opts: []Option{WithVar("name", "message")},
+			exp:  &pb.Message{Name: "message", ResultCount: 42, Nested: &pb.Nested{Bunny: "b"}},
+		},
+		{
+			name: "json",
+			path: "testdata/message.json",
+			opts: []Option{WithVars(vars), WithVar("count", 7)},
+			exp:  &pb.Message{Name: "captured", ResultCount: 7, Children: []*pb.Message{{Name: "child"}}},
+		},
+		{
+			name: "textproto escaped",
+			path: "testdata/message.textproto",
+			opts: []Option{WithVar("name", "a \"quoted\" \\ name\nresult_count: 1")},
+			exp:  &pb.Message{Name: "a \"quoted\" \\ name\nresult_count: 1", ResultCount: 42, Nested: &pb.Nested{Bunny: "b"}},
+		},
+		{
+			name: "json escaped",
+			path: "testdata/message.json",
+			opts: []Option{WithVar("name", `a "quoted", "result_count": 1`), WithVar("count", 7)},
+			exp:  &pb.Message{Name: `a "quoted", "result_count": 1`, ResultCount: 7, Children: []*pb.Message{{Name: "child"}}},
+		},
+		{
+			name: "missing variable",
+			path: "testdata/message.textproto",
+			err:  `map has no entry for key "name"`,
+		},
+		{
+			name: "missing file",
+			path: "testdata/missing.textproto",
+			err:  "failed to read fixture file",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := &pb.Message{}
+			err := Read(tc.path, got, tc.opts...)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("Expected error %q, got: %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !proto.Equal(got, tc.exp) {
+				t.Fatalf("Got %v, want %v", got, tc.exp)
+			}
+		})
+	}
+}
@@ -300,7 +300,10 @@ func (env *TestEnvironment) genCerts() error {
 	if env.settings.TLS == nil {
 		return nil
 	}
-	_, err := cert.GenCert(env.settings.TLS.Hosts)
+	if _, err := cert.GenCert(env.settings.TLS.Hosts); err != nil {
+		return err
+	}
+	_, err := cert.GenClientCert("mtf")
 	return err
 }
 
@@ -311,3 +314,13 @@ func GetTLSCertPath() string {
 func GetTLSKeyPath() string {
 	return cert.ServerKeyFile
 }
+
+// GetTLSClientCertPath returns path of client certificate signed by
+// the mtf CA, SUT can use it to call mTLS server ports.
+func GetTLSClientCertPath() string {
+	return cert.ClientCertFile
+}
+
+func GetTLSClientKeyPath() string {
+	return cert.ClientKeyFile
+}
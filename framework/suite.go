@@ -8,6 +8,7 @@ import (
 
 	"github.com/smallinsky/mtf/framework/context"
 	"github.com/smallinsky/mtf/pkg/netw"
+	"github.com/smallinsky/mtf/port"
 )
 
 type Initable interface {
@@ -48,6 +49,7 @@ func Run(t *testing.T, i interface{}) {
 			}
 		}
 	}
+	verifySuitePorts(t)
 }
 
 func getInternalTests(i interface{}) []testing.InternalTest {
@@ -69,11 +71,34 @@ func getInternalTests(i interface{}) []testing.InternalTest {
 			Name: tm.Name,
 			F: func(t *testing.T) {
 				context.CreateTestContext(t)
+				defer context.RemoveTextContext(t)
+				// Ports are verified also when the test method failed, so
+				// its not received messages don't leak to the next test.
+				defer verifyPorts(t)
 				m.Call([]reflect.Value{reflect.ValueOf(t)})
-				context.RemoveTextContext(t)
 			},
 		})
 	}
 
 	return tests
 }
+
+// verifyPorts fails the test if ports received messages not consumed
+// by the test or call counts of stubs added by the test were not met.
+func verifyPorts(t *testing.T) {
+	for _, p := range port.Ports() {
+		if err := p.VerifyTest(); err != nil {
+			t.Errorf("[MTF ERROR] %v", err)
+		}
+	}
+}
+
+// verifySuitePorts fails the suite if call counts of stubs added in Init
+// were not met by all test methods.
+func verifySuitePorts(t *testing.T) {
+	for _, p := range port.Ports() {
+		if err := p.Verify(); err != nil {
+			t.Errorf("[MTF ERROR] %v", err)
+		}
+	}
+}
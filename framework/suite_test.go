@@ -0,0 +1,183 @@
+package framework
+
+import (
+	ctx "context"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/smallinsky/mtf/framework/context"
+	"github.com/smallinsky/mtf/port"
+	"github.com/smallinsky/mtf/proto/oracle"
+)
+
+type verifySuite struct {
+	svr    *port.Port
+	client oracle.OracleClient
+}
+
+func (s *verifySuite) ask(data string) {
+	go s.client.AskDeepThought(ctx.Background(), &oracle.AskDeepThoughtRequest{
+		Data: data,
+	})
+}
+
+func (s *verifySuite) TestFailWithPendingCall(t *testing.T) {
+	s.ask("first")
+	// Wait for the call to reach the server port.
+	time.Sleep(100 * time.Millisecond)
+	t.Fatalf("test failed before the call was received")
+}
+
+func (s *verifySuite) TestReceiveNextCall(t *testing.T) {
+	s.ask("second")
+	call, _ := s.svr.Receive(t, &oracle.AskDeepThoughtRequest{
+		Data: "second",
+	})
+	call.Send(t, &oracle.AskDeepThoughtResponse{})
+}
+
+// TestSuiteVerifyFailedTest runs the suite in a child process of the test
+// binary, so the expected failure of the first method doesn't fail the test.
+func TestSuiteVerifyFailedTest(t *testing.T) {
+	if os.Getenv("MTF_SUITE_CHILD") != "1" {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestSuiteVerifyFailedTest$", "-test.v")
+		cmd.Env = append(os.Environ(), "MTF_SUITE_CHILD=1")
+		out, _ := cmd.CombinedOutput()
+		for _, line := range []string{
+			"--- FAIL: TestSuiteVerifyFailedTest/TestFailWithPendingCall",
+			// Call not received by the failed test is not delivered to the next one.
+			"--- PASS: TestSuiteVerifyFailedTest/TestReceiveNextCall",
+		} {
+			if !strings.Contains(string(out), line) {
+				t.Fatalf("%q not found in:\n%s", line, out)
+			}
+		}
+		return
+	}
+
+	svr, err := port.NewGRPCServerPort((*oracle.OracleServer)(nil), ":9979")
+	if err != nil {
+		t.Fatal("failed to create grpc server port: ", err)
+	}
+	conn, err := grpc.Dial("localhost:9979", grpc.WithInsecure())
+	if err != nil {
+		t.Fatal("failed to dial grpc server port: ", err)
+	}
+	defer conn.Close()
+
+	context.CreateDirectory()
+	defer os.RemoveAll("runlogs")
+
+	for _, test := range getInternalTests(&verifySuite{
+		svr:    svr,
+		client: oracle.NewOracleClient(conn),
+	}) {
+		t.Run(test.Name, test.F)
+	}
+}
+
+type stubSuite struct {
+	svr    *port.Port
+	client oracle.OracleClient
+}
+
+func (s *stubSuite) TestAddStub(t *testing.T) {
+	s.svr.Stub(t, &oracle.AskDeepThoughtRequest{
+		Data: "ping",
+	}, &oracle.AskDeepThoughtResponse{
+		Data: "pong",
+	})
+	resp, err := s.client.AskDeepThought(ctx.Background(), &oracle.AskDeepThoughtRequest{
+		Data: "ping",
+	})
+	if err != nil || resp.GetData() != "pong" {
+		t.Fatalf("Got: '%v' '%v' Expected: 'pong'", resp.GetData(), err)
+	}
+}
+
+func (s *stubSuite) TestStubRemoved(t *testing.T) {
+	go s.client.AskDeepThought(ctx.Background(), &oracle.AskDeepThoughtRequest{
+		Data: "ping",
+	})
+	call, _ := s.svr.Receive(t, &oracle.AskDeepThoughtRequest{
+		Data: "ping",
+	})
+	call.Send(t, &oracle.AskDeepThoughtResponse{})
+}
+
+func TestSuiteStubsRemovedAfterTest(t *testing.T) {
+	svr, err := port.NewGRPCServerPort((*oracle.OracleServer)(nil), ":9978")
+	if err != nil {
+		t.Fatal("failed to create grpc server port: ", err)
+	}
+	conn, err := grpc.Dial("localhost:9978", grpc.WithInsecure())
+	if err != nil {
+		t.Fatal("failed to dial grpc server port: ", err)
+	}
+	defer conn.Close()
+
+	context.CreateDirectory()
+	defer os.RemoveAll("runlogs")
+
+	// Stub added by the first test doesn't reply in the second one.
+	for _, test := range getInternalTests(&stubSuite{
+		svr:    svr,
+		client: oracle.NewOracleClient(conn),
+	}) {
+		t.Run(test.Name, test.F)
+	}
+}
+
+type suiteStubSuite struct {
+	client oracle.OracleClient
+}
+
+func (s *suiteStubSuite) ping(t *testing.T) {
+	resp, err := s.client.AskDeepThought(ctx.Background(), &oracle.AskDeepThoughtRequest{
+		Data: "ping",
+	})
+	if err != nil || resp.GetData() != "pong" {
+		t.Fatalf("Got: '%v' '%v' Expected: 'pong'", resp.GetData(), err)
+	}
+}
+
+func (s *suiteStubSuite) TestFirstPing(t *testing.T) {
+	s.ping(t)
+}
+
+func (s *suiteStubSuite) TestSecondPing(t *testing.T) {
+	s.ping(t)
+}
+
+func TestSuiteStubTimesCheckedAfterSuite(t *testing.T) {
+	svr, err := port.NewGRPCServerPort((*oracle.OracleServer)(nil), ":9977")
+	if err != nil {
+		t.Fatal("failed to create grpc server port: ", err)
+	}
+	conn, err := grpc.Dial("localhost:9977", grpc.WithInsecure())
+	if err != nil {
+		t.Fatal("failed to dial grpc server port: ", err)
+	}
+	defer conn.Close()
+
+	context.CreateDirectory()
+	defer os.RemoveAll("runlogs")
+
+	// Stub added in Init replies once in each test method.
+	svr.Stub(t, &oracle.AskDeepThoughtRequest{
+		Data: "ping",
+	}, &oracle.AskDeepThoughtResponse{
+		Data: "pong",
+	}, port.WithTimes(2))
+	for _, test := range getInternalTests(&suiteStubSuite{
+		client: oracle.NewOracleClient(conn),
+	}) {
+		t.Run(test.Name, test.F)
+	}
+	verifySuitePorts(t)
+}
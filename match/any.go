@@ -22,3 +22,14 @@ func (m *TypeT) Match(receiveMessageType interface{}) error {
 	}
 	return nil
 }
+
+func (m *TypeT) MatchReceived(err error, got interface{}) error {
+	if err != nil {
+		return errReceived(err)
+	}
+	return m.Match(got)
+}
+
+func (m *TypeT) FailureMessage(got interface{}, err error) string {
+	return failureMessage(m.expectedMessageType, err)
+}
@@ -0,0 +1,235 @@
+package match
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+// BodyType matches decoded body of received HTTP request or JSON payload
+// of received message.
+type BodyType struct {
+	exp    interface{}
+	kind   string
+	path   string
+	decode func(got interface{}) (interface{}, error)
+	differ differ
+	err    error
+}
+
+// JSONEq matches JSON body equal to exp ignoring key order and whitespace.
+// Expected value can be raw JSON as string or []byte, or a value marshaled
+// to JSON.
+func JSONEq(exp interface{}) *BodyType {
+	return jsonBody(exp, differ{})
+}
+
+// JSONSubset matches JSON body containing exp, object keys missing in
+// exp are ignored. Arrays are matched element by element.
+func JSONSubset(exp interface{}) *BodyType {
+	return jsonBody(exp, differ{partial: true})
+}
+
+// JSONPath matches value at JSONPath like `$.items[0].id` of JSON body.
+// Expected value is marshaled to JSON, strings are not parsed as raw JSON.
+func JSONPath(path string, exp interface{}) *BodyType {
+	m := &BodyType{
+		kind:   "json path",
+		path:   path,
+		differ: differ{},
+	}
+	buf, err := json.Marshal(exp)
+	if err != nil {
+		m.err = errors.Wrapf(err, "failed to marshal %T to json", exp)
+		return m
+	}
+	if m.exp, m.err = jsonDocument(buf); m.err != nil {
+		return m
+	}
+	steps, err := parsePath(path)
+	if err == nil && !isJSONPath(path) {
+		err = errors.Errorf("invalid json path %q: must start with '$'", path)
+	}
+	if err != nil {
+		m.err = err
+		return m
+	}
+	m.decode = func(got interface{}) (interface{}, error) {
+		doc, err := jsonDocument(got)
+		if err != nil {
+			return nil, err
+		}
+		return lookupJSON(doc, steps)
+	}
+	return m
+}
+
+func jsonBody(exp interface{}, d differ) *BodyType {
+	m := &BodyType{
+		kind:   "json",
+		path:   "$",
+		decode: jsonDocument,
+		differ: d,
+	}
+	// Round trip through JSON so numbers and nested values have the same
+	// types as decoded body.
+	m.exp, m.err = jsonDocument(jsonValue(exp))
+	return m
+}
+
+// jsonValue returns exp as raw JSON when it is not a JSON document already.
+func jsonValue(exp interface{}) interface{} {
+	switch exp.(type) {
+	case string, []byte, proto.Message:
+		return exp
+	}
+	buf, err := json.Marshal(exp)
+	if err != nil {
+		return exp
+	}
+	return buf
+}
+
+// Form matches form fields of URL encoded body, fields missing in exp
+// are ignored.
+func Form(exp url.Values) *BodyType {
+	return &BodyType{
+		exp:  exp,
+		kind: "form",
+		decode: func(got interface{}) (interface{}, error) {
+			body, _, err := requestBody(got)
+			if err != nil {
+				return nil, err
+			}
+			values, err := url.ParseQuery(string(body))
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to decode form body")
+			}
+			return values, nil
+		},
+		differ: differ{partial: true},
+	}
+}
+
+// MultipartForm is decoded multipart form body.
+type MultipartForm struct {
+	Value map[string][]string
+	File  map[string]FormFile
+}
+
+// FormFile is a file part of multipart form.
+type FormFile struct {
+	Filename string
+	Content  []byte
+}
+
+// Multipart matches multipart form body, values and files missing in exp
+// are ignored. Boundary is read from Content-Type header of received
+// request.
+func Multipart(exp MultipartForm) *BodyType {
+	return &BodyType{
+		exp:    exp,
+		kind:   "multipart",
+		decode: decodeMultipart,
+		differ: differ{partial: true},
+	}
+}
+
+func decodeMultipart(got interface{}) (interface{}, error) {
+	body, header, err := requestBody(got)
+	if err != nil {
+		return nil, err
+	}
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse content type")
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, errors.Errorf("got content type %q, want multipart", mediaType)
+	}
+	form, err := multipart.NewReader(bytes.NewReader(body), params["boundary"]).ReadForm(32 << 20)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode multipart body")
+	}
+	defer form.RemoveAll()
+
+	out := MultipartForm{
+		Value: form.Value,
+		File:  make(map[string]FormFile),
+	}
+	for name, files := range form.File {
+		if len(files) == 0 {
+			continue
+		}
+		f, err := files[0].Open()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to open file %q", name)
+		}
+		content, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read file %q", name)
+		}
+		out.File[name] = FormFile{
+			Filename: files[0].Filename,
+			Content:  content,
+		}
+	}
+	return out, nil
+}
+
+// requestBody returns body and headers of received request, raw bodies
+// have no headers.
+func requestBody(i interface{}) ([]byte, http.Header, error) {
+	switch t := i.(type) {
+	case []byte:
+		return t, http.Header{}, nil
+	case string:
+		return []byte(t), http.Header{}, nil
+	}
+	body, ok := bodyField(i)
+	if !ok {
+		return nil, nil, errors.Errorf("%T has no body", i)
+	}
+	header := http.Header{}
+	v := reflect.Indirect(reflect.ValueOf(i))
+	if f := v.FieldByName("Header"); f.IsValid() && f.Type() == reflect.TypeOf(header) && !f.IsNil() {
+		header = f.Interface().(http.Header)
+	}
+	return body, header, nil
+}
+
+func (m *BodyType) Match(got interface{}) error {
+	if m.err != nil {
+		return m.err
+	}
+	v, err := m.decode(got)
+	if err != nil {
+		return errors.Wrapf(err, "failed to decode %s", m.kind)
+	}
+	diffs := m.differ.diff(m.path, reflect.ValueOf(m.exp), reflect.ValueOf(v))
+	if len(diffs) == 0 {
+		return nil
+	}
+	return errors.Wrapf(ErrNotEq, "%s diff:\n %s\n", m.kind, strings.Join(diffs, "\n "))
+}
+
+func (m *BodyType) MatchReceived(err error, got interface{}) error {
+	if err != nil {
+		return errReceived(err)
+	}
+	return m.Match(got)
+}
+
+func (m *BodyType) FailureMessage(got interface{}, err error) string {
+	return failureMessage(got, err)
+}
@@ -0,0 +1,156 @@
+package match
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	pb "github.com/golang/protobuf/proto/proto3_proto"
+)
+
+func TestBody(t *testing.T) {
+	type request struct {
+		Header http.Header
+		Body   []byte
+	}
+	jsonReq := &request{
+		Body: []byte(`{"id": "o-1", "count": 0, "items": [{"sku": "a", "qty": 2}, {"sku": "b", "qty": 1}]}`),
+	}
+	formReq := &request{
+		Body: []byte("name=mtf&tag=a&tag=b"),
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("name", "mtf")
+	fw, _ := mw.CreateFormFile("file", "data.txt")
+	fw.Write([]byte("content"))
+	mw.Close()
+	multipartReq := &request{
+		Header: http.Header{"Content-Type": {mw.FormDataContentType()}},
+		Body:   buf.Bytes(),
+	}
+
+	cases := []struct {
+		name    string
+		matcher *BodyType
+		got     interface{}
+		diffs   []string
+	}{
+		{
+			name:    "json equal",
+			matcher: JSONEq(`{"items":[{"qty":2,"sku":"a"},{"qty":1,"sku":"b"}],"count":0,"id":"o-1"}`),
+			got:     jsonReq,
+		},
+		{
+			name:    "json equal struct",
+			matcher: JSONEq(map[string]interface{}{"id": "o-1", "count": 0, "items": []map[string]interface{}{{"sku": "a", "qty": 2}, {"sku": "b", "qty": 1}}}),
+			got:     jsonReq,
+		},
+		{
+			name:    "json not equal",
+			matcher: JSONEq(`{"id": "o-2", "items": [{"sku": "a", "qty": 2}, {"sku": "b", "qty": 1}]}`),
+			got:     jsonReq,
+			diffs: []string{
+				`$["id"]: got "o-1", want "o-2"`,
+				`$["count"]: unexpected 0`,
+			},
+		},
+		{
+			name:    "json subset",
+			matcher: JSONSubset(`{"id": "o-1", "items": [{"sku": "a"}, {"sku": "b"}]}`),
+			got:     jsonReq,
+		},
+		{
+			name:    "json subset mismatch",
+			matcher: JSONSubset(`{"count": 1, "items": [{"qty": 1}, {}]}`),
+			got:     jsonReq,
+			diffs: []string{
+				`$["count"]: got 0, want 1`,
+				`$["items"][0]["qty"]: got 2, want 1`,
+			},
+		},
+		{
+			name:    "json path",
+			matcher: JSONPath("$.items[1].sku", "b"),
+			got:     jsonReq,
+		},
+		{
+			name:    "json path mismatch",
+			matcher: JSONPath("$.items[0].qty", 3),
+			got:     jsonReq,
+			diffs: []string{
+				"$.items[0].qty: got 2, want 3",
+			},
+		},
+		{
+			name:    "json path missing",
+			matcher: JSONPath("$.items[2].sku", "c"),
+			got:     jsonReq,
+			diffs: []string{
+				"index out of range",
+			},
+		},
+		{
+			name:    "json path of proto",
+			matcher: JSONPath("$.nested.bunny", "b"),
+			got:     &pb.Message{Nested: &pb.Nested{Bunny: "b"}},
+		},
+		{
+			name:    "form",
+			matcher: Form(url.Values{"tag": {"a", "b"}}),
+			got:     formReq,
+		},
+		{
+			name:    "form mismatch",
+			matcher: Form(url.Values{"name": {"other"}}),
+			got:     formReq,
+			diffs: []string{
+				`["name"][0]: got "mtf", want "other"`,
+			},
+		},
+		{
+			name: "multipart",
+			matcher: Multipart(MultipartForm{
+				Value: map[string][]string{"name": {"mtf"}},
+				File:  map[string]FormFile{"file": {Filename: "data.txt", Content: []byte("content")}},
+			}),
+			got: multipartReq,
+		},
+		{
+			name: "multipart mismatch",
+			matcher: Multipart(MultipartForm{
+				File: map[string]FormFile{"file": {Content: []byte("other")}},
+			}),
+			got: multipartReq,
+			diffs: []string{
+				`File["file"].Content: got "content", want "other"`,
+			},
+		},
+		{
+			name:    "multipart without content type",
+			matcher: Multipart(MultipartForm{}),
+			got:     formReq,
+			diffs: []string{
+				"failed to decode multipart",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.matcher.MatchReceived(nil, tc.got)
+			if got, exp := err != nil, len(tc.diffs) > 0; got != exp {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for _, d := range tc.diffs {
+				if !strings.Contains(err.Error(), d) {
+					t.Fatalf("Diff %q not found in:\n%v", d, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package match
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// Vars holds values captured from received messages by name, so they
+// can be used in expectations and messages of later test steps.
+type Vars struct {
+	mtx    sync.Mutex
+	values map[string]interface{}
+}
+
+func NewVars() *Vars {
+	return &Vars{
+		values: make(map[string]interface{}),
+	}
+}
+
+// Capture returns matcher binding value at path of message matched by m
+// to variable name. Path is a field path like `items[0].id` or JSONPath
+// like `$.items[0].id`, JSON values are captured as decoded by
+// encoding/json. When m is nil any received message is captured.
+func (v *Vars) Capture(m ReceiveMatcher, name, path string) *CaptureType {
+	return (&CaptureType{
+		m:    m,
+		vars: v,
+	}).Capture(name, path)
+}
+
+// Lookup returns value of captured variable and reports whether it was
+// captured.
+func (v *Vars) Lookup(name string) (interface{}, bool) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	val, ok := v.values[name]
+	return val, ok
+}
+
+// Get returns value of captured variable, test fails if the variable was
+// not captured yet.
+func (v *Vars) Get(t *testing.T, name string) interface{} {
+	t.Helper()
+	val, ok := v.Lookup(name)
+	if !ok {
+		t.Fatalf("variable %q not captured", name)
+	}
+	return val
+}
+
+// String returns captured variable formatted as string.
+func (v *Vars) String(t *testing.T, name string) string {
+	t.Helper()
+	val := v.Get(t, name)
+	if s, ok := val.(string); ok {
+		return s
+	}
+	return fmt.Sprint(val)
+}
+
+// Values returns copy of captured variables.
+func (v *Vars) Values() map[string]interface{} {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	out := make(map[string]interface{}, len(v.values))
+	for k, val := range v.values {
+		out[k] = val
+	}
+	return out
+}
+
+func (v *Vars) Set(name string, val interface{}) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	v.values[name] = val
+}
+
+type CaptureType struct {
+	m     ReceiveMatcher
+	vars  *Vars
+	binds []bind
+}
+
+type bind struct {
+	name string
+	path string
+}
+
+// Capture binds another path of matched message to variable name.
+func (c *CaptureType) Capture(name, path string) *CaptureType {
+	c.binds = append(c.binds, bind{name: name, path: path})
+	return c
+}
+
+func (c *CaptureType) MatchReceived(err error, got interface{}) error {
+	if c.m != nil {
+		if err := c.m.MatchReceived(err, got); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return errReceived(err)
+	}
+
+	values := make(map[string]interface{}, len(c.binds))
+	for _, b := range c.binds {
+		val, err := lookupPath(got, b.path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to capture %q", b.name)
+		}
+		values[b.name] = val
+	}
+	for name, val := range values {
+		c.vars.Set(name, val)
+	}
+	return nil
+}
+
+func (c *CaptureType) FailureMessage(got interface{}, err error) string {
+	if c.m != nil {
+		return c.m.FailureMessage(got, err)
+	}
+	return failureMessage(got, err)
+}
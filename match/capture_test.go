@@ -0,0 +1,144 @@
+package match
+
+import (
+	"reflect"
+	"testing"
+
+	pb "github.com/golang/protobuf/proto/proto3_proto"
+)
+
+func TestCapture(t *testing.T) {
+	msg := &pb.Message{
+		Name:        "message",
+		ResultCount: 42,
+		Nested:      &pb.Nested{Bunny: "volatile"},
+		Terrain:     map[string]*pb.Nested{"north": {Bunny: "n"}},
+		Children:    []*pb.Message{{Name: "first"}, {Name: "second"}},
+	}
+	type request struct {
+		Method string
+		Body   []byte
+	}
+	req := &request{
+		Method: "POST",
+		Body:   []byte(`{"order": {"id": "o-1", "items": [{"sku": "a"}, {"sku": "b"}]}}`),
+	}
+
+	cases := []struct {
+		name    string
+		matcher ReceiveMatcher
+		got     interface{}
+		path    string
+		exp     interface{}
+		fail    bool
+	}{
+		{
+			name: "field",
+			got:  msg,
+			path: "result_count",
+			exp:  int64(42),
+		},
+		{
+			name: "nested field",
+			got:  msg,
+			path: "nested.bunny",
+			exp:  "volatile",
+		},
+		{
+			name: "repeated field",
+			got:  msg,
+			path: "children[1].name",
+			exp:  "second",
+		},
+		{
+			name: "map field",
+			got:  msg,
+			path: `terrain["north"].bunny`,
+			exp:  "n",
+		},
+		{
+			name: "missing field",
+			got:  msg,
+			path: "children[2].name",
+			fail: true,
+		},
+		{
+			name: "json path of proto",
+			got:  msg,
+			path: "$.children[0].name",
+			exp:  "first",
+		},
+		{
+			name: "json path of body",
+			got:  req,
+			path: "$.order.items[1].sku",
+			exp:  "b",
+		},
+		{
+			name: "json path of raw json",
+			got:  []byte(`{"id": 7}`),
+			path: "$['id']",
+			exp:  float64(7),
+		},
+		{
+			name:    "matcher",
+			matcher: Partial(&pb.Message{Name: "message"}),
+			got:     msg,
+			path:    "nested.bunny",
+			exp:     "volatile",
+		},
+		{
+			name:    "matcher failed",
+			matcher: Partial(&pb.Message{Name: "other"}),
+			got:     msg,
+			path:    "nested.bunny",
+			fail:    true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			vars := NewVars()
+			err := vars.Capture(tc.matcher, "v", tc.path).MatchReceived(nil, tc.got)
+			if got, exp := err != nil, tc.fail; got != exp {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tc.fail {
+				if _, ok := vars.values["v"]; ok {
+					t.Fatalf("Variable captured on failed match")
+				}
+				return
+			}
+			if got := vars.Get(t, "v"); !reflect.DeepEqual(got, tc.exp) {
+				t.Fatalf("Captured %#v, want %#v", got, tc.exp)
+			}
+		})
+	}
+}
+
+func TestCaptureMultiple(t *testing.T) {
+	vars := NewVars()
+	m := vars.Capture(nil, "name", "name").Capture("bunny", "nested.bunny")
+	if err := m.MatchReceived(nil, &pb.Message{Name: "message", Nested: &pb.Nested{Bunny: "b"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if vars.String(t, "name") != "message" || vars.String(t, "bunny") != "b" {
+		t.Fatalf("Unexpected variables: %v", vars.values)
+	}
+
+	err := Partial(&pb.Message{Name: vars.String(t, "name")}).Match(&pb.Message{Name: "message"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestVarsLookup(t *testing.T) {
+	vars := NewVars()
+	if _, ok := vars.Lookup("missing"); ok {
+		t.Fatalf("Missing variable found")
+	}
+	vars.Set("id", 7)
+	if v, ok := vars.Lookup("id"); !ok || v != 7 {
+		t.Fatalf("Unexpected variable: %v, %v", v, ok)
+	}
+}
@@ -0,0 +1,344 @@
+package match
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// describe returns readable name of matcher m used in failure messages.
+func describe(m ReceiveMatcher) string {
+	if s, ok := m.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", m)
+}
+
+func describeAll(ms []ReceiveMatcher) string {
+	names := make([]string, 0, len(ms))
+	for _, m := range ms {
+		names = append(names, describe(m))
+	}
+	return strings.Join(names, ", ")
+}
+
+type AllType struct {
+	ms []ReceiveMatcher
+}
+
+// All matches when all matchers ms match.
+func All(ms ...ReceiveMatcher) *AllType {
+	return &AllType{
+		ms: ms,
+	}
+}
+
+func (m *AllType) MatchReceived(err error, got interface{}) error {
+	for _, sub := range m.ms {
+		if e := sub.MatchReceived(err, got); e != nil {
+			return errors.Wrapf(e, "%s failed", describe(sub))
+		}
+	}
+	return nil
+}
+
+func (m *AllType) FailureMessage(got interface{}, err error) string {
+	return failureMessage(got, err)
+}
+
+func (m *AllType) String() string {
+	return "all(" + describeAll(m.ms) + ")"
+}
+
+type AnyType struct {
+	ms []ReceiveMatcher
+}
+
+// Any matches when at least one of matchers ms matches.
+func Any(ms ...ReceiveMatcher) *AnyType {
+	return &AnyType{
+		ms: ms,
+	}
+}
+
+func (m *AnyType) MatchReceived(err error, got interface{}) error {
+	var fails []string
+	for _, sub := range m.ms {
+		e := sub.MatchReceived(err, got)
+		if e == nil {
+			return nil
+		}
+		fails = append(fails, fmt.Sprintf("%s: %v", describe(sub), e))
+	}
+	return errors.Wrapf(ErrNotEq, "none of matchers matched:\n %s\n", strings.Join(fails, "\n "))
+}
+
+func (m *AnyType) FailureMessage(got interface{}, err error) string {
+	return failureMessage(got, err)
+}
+
+func (m *AnyType) String() string {
+	return "any(" + describeAll(m.ms) + ")"
+}
+
+type NotType struct {
+	m ReceiveMatcher
+}
+
+// Not matches when matcher m doesn't match.
+func Not(m ReceiveMatcher) *NotType {
+	return &NotType{
+		m: m,
+	}
+}
+
+func (m *NotType) MatchReceived(err error, got interface{}) error {
+	if m.m.MatchReceived(err, got) == nil {
+		return errors.Wrapf(ErrNotEq, "%s matched", describe(m.m))
+	}
+	return nil
+}
+
+func (m *NotType) FailureMessage(got interface{}, err error) string {
+	return failureMessage(got, err)
+}
+
+func (m *NotType) String() string {
+	return "not(" + describe(m.m) + ")"
+}
+
+type FieldType struct {
+	path string
+	m    ReceiveMatcher
+}
+
+// Field applies matcher m to value at field path like `items[0].id` or
+// JSONPath like `$.items[0].id` of received message.
+func Field(path string, m ReceiveMatcher) *FieldType {
+	return &FieldType{
+		path: path,
+		m:    m,
+	}
+}
+
+func (m *FieldType) MatchReceived(err error, got interface{}) error {
+	if err != nil {
+		return errReceived(err)
+	}
+	v, err := lookupPath(got, m.path)
+	if err != nil {
+		return err
+	}
+	if err := m.m.MatchReceived(nil, v); err != nil {
+		return errors.Wrapf(err, "field %q", m.path)
+	}
+	return nil
+}
+
+func (m *FieldType) FailureMessage(got interface{}, err error) string {
+	return failureMessage(got, err)
+}
+
+func (m *FieldType) String() string {
+	return fmt.Sprintf("field(%q, %s)", m.path, describe(m.m))
+}
+
+// PredicateType matches received value with a predicate like Regex or Len.
+type PredicateType struct {
+	name string
+	fn   func(got interface{}) error
+}
+
+func predicate(name string, fn func(got interface{}) error) *PredicateType {
+	return &PredicateType{
+		name: name,
+		fn:   fn,
+	}
+}
+
+func (m *PredicateType) MatchReceived(err error, got interface{}) error {
+	if err != nil {
+		return errReceived(err)
+	}
+	return m.fn(got)
+}
+
+func (m *PredicateType) FailureMessage(got interface{}, err error) string {
+	return failureMessage(got, err)
+}
+
+func (m *PredicateType) String() string {
+	return m.name
+}
+
+// Regex matches string or []byte value matching regular expression expr.
+func Regex(expr string) *PredicateType {
+	re, reErr := regexp.Compile(expr)
+	return predicate(fmt.Sprintf("regex(%q)", expr), func(got interface{}) error {
+		if reErr != nil {
+			return errors.Wrapf(reErr, "invalid regex")
+		}
+		s, err := toString(got)
+		if err != nil {
+			return err
+		}
+		if !re.MatchString(s) {
+			return errors.Wrapf(ErrNotEq, "%q doesn't match %q", s, expr)
+		}
+		return nil
+	})
+}
+
+// Contains matches string containing substring, slice containing element
+// or map containing key x.
+func Contains(x interface{}) *PredicateType {
+	return predicate(fmt.Sprintf("contains(%#v)", x), func(got interface{}) error {
+		v := normalize(reflect.ValueOf(got))
+		if v.IsValid() && (v.Kind() == reflect.String || v.Type() == reflect.TypeOf([]byte(nil))) {
+			s, _ := toString(got)
+			sub, err := toString(x)
+			if err != nil {
+				return err
+			}
+			if !strings.Contains(s, sub) {
+				return errors.Wrapf(ErrNotEq, "%q doesn't contain %q", s, sub)
+			}
+			return nil
+		}
+		if !v.IsValid() {
+			return errors.Errorf("got nil, want string, slice or map")
+		}
+
+		switch v.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < v.Len(); i++ {
+				e := normalize(v.Index(i))
+				if !e.IsValid() {
+					// Nil element, like JSON null, is contained only by nil x.
+					if x == nil {
+						return nil
+					}
+					continue
+				}
+				if reflect.DeepEqual(e.Interface(), x) {
+					return nil
+				}
+			}
+		case reflect.Map:
+			k := reflect.ValueOf(x)
+			if k.IsValid() && k.Type().ConvertibleTo(v.Type().Key()) && v.MapIndex(k.Convert(v.Type().Key())).IsValid() {
+				return nil
+			}
+		default:
+			return errors.Errorf("got %T, want string, slice or map", got)
+		}
+		return errors.Wrapf(ErrNotEq, "%+v doesn't contain %#v", got, x)
+	})
+}
+
+// Len matches string, slice or map of length n.
+func Len(n int) *PredicateType {
+	return predicate(fmt.Sprintf("len(%d)", n), func(got interface{}) error {
+		l, err := length(got)
+		if err != nil {
+			return err
+		}
+		if l != n {
+			return errors.Wrapf(ErrNotEq, "got len %d, want %d", l, n)
+		}
+		return nil
+	})
+}
+
+// Empty matches zero value or empty string, slice or map.
+func Empty() *PredicateType {
+	return predicate("empty", func(got interface{}) error {
+		if !isUnset(reflect.ValueOf(got)) {
+			return errors.Wrapf(ErrNotEq, "got %+v, want empty", got)
+		}
+		return nil
+	})
+}
+
+// InRange matches number between min and max inclusive.
+func InRange(min, max float64) *PredicateType {
+	return predicate(fmt.Sprintf("in_range(%v, %v)", min, max), func(got interface{}) error {
+		f, err := toFloat(got)
+		if err != nil {
+			return err
+		}
+		if f < min || f > max {
+			return errors.Wrapf(ErrNotEq, "got %v, want in range [%v, %v]", f, min, max)
+		}
+		return nil
+	})
+}
+
+// GreaterThan matches number greater than x.
+func GreaterThan(x float64) *PredicateType {
+	return predicate(fmt.Sprintf("greater_than(%v)", x), func(got interface{}) error {
+		f, err := toFloat(got)
+		if err != nil {
+			return err
+		}
+		if f <= x {
+			return errors.Wrapf(ErrNotEq, "got %v, want greater than %v", f, x)
+		}
+		return nil
+	})
+}
+
+// LessThan matches number less than x.
+func LessThan(x float64) *PredicateType {
+	return predicate(fmt.Sprintf("less_than(%v)", x), func(got interface{}) error {
+		f, err := toFloat(got)
+		if err != nil {
+			return err
+		}
+		if f >= x {
+			return errors.Wrapf(ErrNotEq, "got %v, want less than %v", f, x)
+		}
+		return nil
+	})
+}
+
+func toString(i interface{}) (string, error) {
+	v := normalize(reflect.ValueOf(i))
+	switch {
+	case !v.IsValid():
+	case v.Kind() == reflect.String:
+		return v.String(), nil
+	case v.Type() == reflect.TypeOf([]byte(nil)):
+		return string(v.Bytes()), nil
+	}
+	return "", errors.Errorf("got %T, want string", i)
+}
+
+func toFloat(i interface{}) (float64, error) {
+	v := normalize(reflect.ValueOf(i))
+	if v.IsValid() {
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return float64(v.Int()), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return float64(v.Uint()), nil
+		case reflect.Float32, reflect.Float64:
+			return v.Float(), nil
+		}
+	}
+	return 0, errors.Errorf("got %T, want number", i)
+}
+
+func length(i interface{}) (int, error) {
+	v := normalize(reflect.ValueOf(i))
+	if v.IsValid() {
+		switch v.Kind() {
+		case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+			return v.Len(), nil
+		}
+	}
+	return 0, errors.Errorf("got %T, want string, slice or map", i)
+}
@@ -0,0 +1,160 @@
+package match
+
+import (
+	"strings"
+	"testing"
+
+	pb "github.com/golang/protobuf/proto/proto3_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCompose(t *testing.T) {
+	msg := &pb.Message{
+		Name:        "q-42",
+		ResultCount: 42,
+		Score:       0.5,
+		Key:         []uint64{1, 2, 3},
+		Nested:      &pb.Nested{Bunny: "volatile"},
+		Terrain:     map[string]*pb.Nested{"north": {Bunny: "n"}},
+	}
+
+	cases := []struct {
+		name    string
+		matcher ReceiveMatcher
+		got     interface{}
+		err     error
+		fails   []string
+	}{
+		{
+			name: "all",
+			matcher: All(
+				Type(&pb.Message{}),
+				Field("name", Regex(`^q-\d+$`)),
+				Field("nested.bunny", Not(Empty())),
+			),
+			got: msg,
+		},
+		{
+			name: "all fails",
+			matcher: All(
+				Field("name", Regex(`^q-\d+$`)),
+				Field("nested.cute", Not(Empty())),
+			),
+			got: msg,
+			fails: []string{
+				`field("nested.cute", not(empty)) failed`,
+				"empty matched",
+			},
+		},
+		{
+			name:    "any",
+			matcher: Any(Field("name", Contains("x")), Field("name", Contains("42"))),
+			got:     msg,
+		},
+		{
+			name:    "any fails",
+			matcher: Any(Field("name", Contains("x")), Field("result_count", LessThan(10))),
+			got:     msg,
+			fails: []string{
+				`field("name", contains("x"))`,
+				"got 42, want less than 10",
+			},
+		},
+		{
+			name:    "not",
+			matcher: Not(Field("name", Regex("^a"))),
+			got:     msg,
+		},
+		{
+			name:    "contains element",
+			matcher: Field("key", Contains(uint64(2))),
+			got:     msg,
+		},
+		{
+			name:    "contains key",
+			matcher: Field("terrain", Contains("north")),
+			got:     msg,
+		},
+		{
+			name:    "contains missing key",
+			matcher: Field("terrain", Contains("south")),
+			got:     msg,
+			fails:   []string{`field "terrain"`},
+		},
+		{
+			name:    "len",
+			matcher: Field("key", Len(3)),
+			got:     msg,
+		},
+		{
+			name:    "len fails",
+			matcher: Field("name", Len(2)),
+			got:     msg,
+			fails:   []string{"got len 4, want 2"},
+		},
+		{
+			name:    "range",
+			matcher: All(Field("score", InRange(0, 1)), Field("result_count", GreaterThan(41))),
+			got:     msg,
+		},
+		{
+			name:    "range fails",
+			matcher: Field("score", InRange(1, 2)),
+			got:     msg,
+			fails:   []string{"got 0.5, want in range [1, 2]"},
+		},
+		{
+			name:    "json path field",
+			matcher: Field("$.items[0].qty", GreaterThan(1)),
+			got:     []byte(`{"items": [{"qty": 2}]}`),
+		},
+		{
+			name:    "contains with null element",
+			matcher: Field("$.items", Contains("a")),
+			got:     []byte(`{"items": [null, "a"]}`),
+		},
+		{
+			name:    "contains null element",
+			matcher: Field("$.items", Contains(nil)),
+			got:     []byte(`{"items": ["a", null]}`),
+		},
+		{
+			name:    "contains missing with null element",
+			matcher: Field("$.items", Contains("b")),
+			got:     []byte(`{"items": [null, "a"]}`),
+			fails:   []string{`doesn't contain "b"`},
+		},
+		{
+			name:    "missing field",
+			matcher: Field("unknown", Empty()),
+			got:     msg,
+			fails:   []string{"field not found"},
+		},
+		{
+			name:    "received error",
+			matcher: Any(Field("name", Empty()), GRPCStatusCode(codes.NotFound)),
+			err:     status.Error(codes.NotFound, "not found"),
+		},
+		{
+			name:    "invalid regex",
+			matcher: Regex("("),
+			got:     "",
+			fails:   []string{"invalid regex"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.matcher.MatchReceived(tc.err, tc.got)
+			if got, exp := err != nil, len(tc.fails) > 0; got != exp {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for _, f := range tc.fails {
+				if !strings.Contains(err.Error(), f) {
+					t.Fatalf("%q not found in:\n%v", f, err)
+				}
+			}
+		})
+	}
+}
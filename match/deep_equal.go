@@ -18,6 +18,9 @@ func DeepEqual(exp interface{}) *DeepEqualType {
 }
 
 func (m *DeepEqualType) Match(got interface{}) error {
+	if isDynamic(got) || isDynamic(m.exp) {
+		return ProtoEqual(m.exp).Match(got)
+	}
 	if reflect.DeepEqual(got, m.exp) {
 		return nil
 	}
@@ -47,3 +50,14 @@ func toJsonString(i interface{}) (string, error) {
 	}
 	return string(buff), nil
 }
+
+func (m *DeepEqualType) MatchReceived(err error, got interface{}) error {
+	if err != nil {
+		return errReceived(err)
+	}
+	return m.Match(got)
+}
+
+func (m *DeepEqualType) FailureMessage(got interface{}, err error) string {
+	return failureMessage(m.exp, err)
+}
@@ -22,3 +22,14 @@ func (m *DiffType) Match(got interface{}) error {
 	}
 	return nil
 }
+
+func (m *DiffType) MatchReceived(err error, got interface{}) error {
+	if err != nil {
+		return errReceived(err)
+	}
+	return m.Match(got)
+}
+
+func (m *DiffType) FailureMessage(got interface{}, err error) string {
+	return failureMessage(m.exp, err)
+}
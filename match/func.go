@@ -82,3 +82,14 @@ func (m *FnType) Validate() error {
 	}
 	return nil
 }
+
+func (m *FnType) MatchReceived(err error, got interface{}) error {
+	if err != nil {
+		return errReceived(err)
+	}
+	return m.Match(nil, got)
+}
+
+func (m *FnType) FailureMessage(got interface{}, err error) string {
+	return failureMessage(m, err)
+}
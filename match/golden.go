@@ -0,0 +1,166 @@
+package match
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/pkg/errors"
+)
+
+var updateGolden bool
+
+func init() {
+	flag.BoolVar(&updateGolden, "mtf.update", false,
+		"Rewrite golden files of match.Golden with received messages")
+}
+
+type GoldenType struct {
+	path string
+}
+
+// Golden matches received message with golden file at path. Files with
+// .json extension are compared as JSON, proto messages are compared as
+// textproto and bodies of HTTP requests or storage objects byte by byte.
+// Running tests with -mtf.update flag rewrites golden files with received
+// messages.
+func Golden(path string) *GoldenType {
+	return &GoldenType{
+		path: path,
+	}
+}
+
+func (m *GoldenType) Match(got interface{}) error {
+	if updateGolden {
+		return m.update(got)
+	}
+	buf, err := ioutil.ReadFile(m.path)
+	if os.IsNotExist(err) {
+		return errors.Errorf("golden file %s not found, run tests with -mtf.update flag to create it", m.path)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to read golden file")
+	}
+
+	switch {
+	case m.isJSON():
+		exp, err := jsonDocument(buf)
+		if err != nil {
+			return errors.Wrapf(err, "invalid golden file %s", m.path)
+		}
+		return m.diffJSON(exp, got)
+	case isProtoMessage(got):
+		exp, err := unmarshalText(buf, got.(proto.Message))
+		if err != nil {
+			return errors.Wrapf(err, "invalid golden file %s", m.path)
+		}
+		if err := ProtoDiff(exp).Match(got); err != nil {
+			return errors.Wrapf(err, "golden file %s", m.path)
+		}
+		return nil
+	default:
+		body, err := goldenBody(got)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(body, buf) {
+			return errors.Wrapf(ErrNotEq, "golden file %s: got %q, want %q", m.path, body, buf)
+		}
+		return nil
+	}
+}
+
+func (m *GoldenType) diffJSON(exp, got interface{}) error {
+	doc, err := jsonDocument(got)
+	if err != nil {
+		return err
+	}
+	var d differ
+	diffs := d.diff("$", reflect.ValueOf(exp), reflect.ValueOf(doc))
+	if len(diffs) == 0 {
+		return nil
+	}
+	return errors.Wrapf(ErrNotEq, "golden file %s diff:\n %s\n", m.path, strings.Join(diffs, "\n "))
+}
+
+func (m *GoldenType) update(got interface{}) error {
+	var (
+		buf []byte
+		err error
+	)
+	switch {
+	case m.isJSON():
+		var doc interface{}
+		if doc, err = jsonDocument(got); err == nil {
+			buf, err = json.MarshalIndent(doc, "", "  ")
+			buf = append(buf, '\n')
+		}
+	case isProtoMessage(got):
+		buf = []byte(proto.MarshalTextString(got.(proto.Message)))
+	default:
+		buf, err = goldenBody(got)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to update golden file %s", m.path)
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return errors.Wrapf(err, "failed to create golden file dir")
+	}
+	if err := ioutil.WriteFile(m.path, buf, 0644); err != nil {
+		return errors.Wrapf(err, "failed to write golden file")
+	}
+	return nil
+}
+
+func (m *GoldenType) isJSON() bool {
+	return strings.EqualFold(filepath.Ext(m.path), ".json")
+}
+
+func (m *GoldenType) MatchReceived(err error, got interface{}) error {
+	if err != nil {
+		return errReceived(err)
+	}
+	return m.Match(got)
+}
+
+func (m *GoldenType) FailureMessage(got interface{}, err error) string {
+	return failureMessage(got, err)
+}
+
+func isProtoMessage(i interface{}) bool {
+	_, ok := i.(proto.Message)
+	return ok
+}
+
+// unmarshalText parses textproto buf into new message of the same type
+// as msg.
+func unmarshalText(buf []byte, msg proto.Message) (proto.Message, error) {
+	if dm, ok := msg.(*dynamic.Message); ok {
+		out := dynamic.NewMessage(dm.GetMessageDescriptor())
+		return out, out.UnmarshalText(buf)
+	}
+	out := reflect.New(reflect.TypeOf(msg).Elem()).Interface().(proto.Message)
+	return out, proto.UnmarshalText(string(buf), out)
+}
+
+// goldenBody returns raw payload of received message compared with
+// golden file.
+func goldenBody(got interface{}) ([]byte, error) {
+	switch t := got.(type) {
+	case []byte:
+		return t, nil
+	case string:
+		return []byte(t), nil
+	}
+	if body, ok := bodyField(got); ok {
+		return body, nil
+	}
+	return nil, errors.Errorf("can't compare %T with golden file", got)
+}
@@ -0,0 +1,90 @@
+package match
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pb "github.com/golang/protobuf/proto/proto3_proto"
+)
+
+// Test binaries commonly define own -update flag, it must not collide with
+// the golden flag of this package.
+var _ = flag.Bool("update", false, "update golden files")
+
+func TestGolden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mtf-golden")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	type request struct {
+		Body []byte
+	}
+	msg := &pb.Message{Name: "message", Nested: &pb.Nested{Bunny: "b"}}
+
+	cases := []struct {
+		name  string
+		file  string
+		got   interface{}
+		other interface{}
+		diff  string
+	}{
+		{
+			name:  "textproto",
+			file:  "message.textproto",
+			got:   msg,
+			other: &pb.Message{Name: "message", Nested: &pb.Nested{Bunny: "c"}},
+			diff:  `nested.bunny: got "c", want "b"`,
+		},
+		{
+			name:  "proto json",
+			file:  "message.json",
+			got:   msg,
+			other: &pb.Message{Name: "other", Nested: &pb.Nested{Bunny: "b"}},
+			diff:  `$["name"]: got "other", want "message"`,
+		},
+		{
+			name:  "json body",
+			file:  "body.json",
+			got:   &request{Body: []byte(`{"id": 1, "items": ["a"]}`)},
+			other: &request{Body: []byte(`{"items": ["a"], "id": 2}`)},
+			diff:  `$["id"]: got 2, want 1`,
+		},
+		{
+			name:  "raw body",
+			file:  "nested/body.txt",
+			got:   &request{Body: []byte("content")},
+			other: &request{Body: []byte("other")},
+			diff:  `got "other", want "content"`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := Golden(filepath.Join(dir, tc.file))
+			if err := m.Match(tc.got); err == nil || !strings.Contains(err.Error(), "-mtf.update") {
+				t.Fatalf("Expected missing golden file error, got: %v", err)
+			}
+
+			updateGolden = true
+			err := m.MatchReceived(nil, tc.got)
+			updateGolden = false
+			if err != nil {
+				t.Fatalf("Failed to update golden file: %v", err)
+			}
+
+			if err := m.MatchReceived(nil, tc.got); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			err = m.MatchReceived(nil, tc.other)
+			if err == nil || !strings.Contains(err.Error(), tc.diff) {
+				t.Fatalf("Diff %q not found in: %v", tc.diff, err)
+			}
+		})
+	}
+}
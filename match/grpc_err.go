@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/golang/protobuf/proto"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -11,6 +12,8 @@ import (
 type GRPCErrType struct {
 	Code    codes.Code
 	Message string
+	// Details are expected to be found in error status details.
+	Details []proto.Message
 }
 
 func GRPCErr(code codes.Code, msg string) *GRPCErrType {
@@ -26,6 +29,13 @@ func GRPCStatusCode(code codes.Code) *GRPCErrType {
 	}
 }
 
+// WithDetails sets error status details expected by the matcher. Each of
+// expected details has to be equal to one of the status details.
+func (m *GRPCErrType) WithDetails(details ...proto.Message) *GRPCErrType {
+	m.Details = append(m.Details, details...)
+	return m
+}
+
 func (m *GRPCErrType) Match(err error) error {
 	s, ok := status.FromError(err)
 	if !ok {
@@ -34,11 +44,30 @@ func (m *GRPCErrType) Match(err error) error {
 	if got, want := s.Code(), m.Code; got != want {
 		return fmt.Errorf("got unexpected error status code:\nGot:  '%v'\nWant: '%v'", got, want)
 	}
-	if m.Message == "" {
-		return nil
-	}
 	if got, want := s.Message(), m.Message; !strings.Contains(got, want) {
 		return fmt.Errorf("unexpected grpc error message:\n Got: '%s'\nWant: '%s'", got, want)
 	}
+	for _, want := range m.Details {
+		if !hasDetail(s.Details(), want) {
+			return fmt.Errorf("grpc error detail not found:\nGot:  '%v'\nWant: '%T{%v}'", s.Details(), want, want)
+		}
+	}
 	return nil
 }
+
+func hasDetail(details []interface{}, want proto.Message) bool {
+	for _, d := range details {
+		if got, ok := d.(proto.Message); ok && proto.Equal(got, want) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *GRPCErrType) MatchReceived(err error, got interface{}) error {
+	return m.Match(err)
+}
+
+func (m *GRPCErrType) FailureMessage(got interface{}, err error) string {
+	return fmt.Sprintf("Failed to receive GRPC error: %v", err)
+}
@@ -0,0 +1,83 @@
+package match
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCErr(t *testing.T) {
+	retry := &errdetails.RetryInfo{
+		RetryDelay: ptypes.DurationProto(5),
+	}
+	badRequest := &errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: "data", Description: "empty"},
+		},
+	}
+	st, err := status.New(codes.Unavailable, "service unavailable").WithDetails(retry, badRequest)
+	if err != nil {
+		t.Fatalf("failed to create status: %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		matcher *GRPCErrType
+		err     error
+		fail    bool
+	}{
+		{
+			name:    "code",
+			matcher: GRPCStatusCode(codes.Unavailable),
+			err:     st.Err(),
+		},
+		{
+			name:    "wrong code",
+			matcher: GRPCStatusCode(codes.Internal),
+			err:     st.Err(),
+			fail:    true,
+		},
+		{
+			name:    "message",
+			matcher: GRPCErr(codes.Unavailable, "unavailable"),
+			err:     st.Err(),
+		},
+		{
+			name:    "details",
+			matcher: GRPCStatusCode(codes.Unavailable).WithDetails(badRequest, retry),
+			err:     st.Err(),
+		},
+		{
+			name: "different detail value",
+			matcher: GRPCStatusCode(codes.Unavailable).WithDetails(&errdetails.RetryInfo{
+				RetryDelay: ptypes.DurationProto(10),
+			}),
+			err:  st.Err(),
+			fail: true,
+		},
+		{
+			name:    "missing detail",
+			matcher: GRPCStatusCode(codes.Unavailable).WithDetails(retry),
+			err:     status.Error(codes.Unavailable, "service unavailable"),
+			fail:    true,
+		},
+		{
+			name:    "not grpc error",
+			matcher: GRPCStatusCode(codes.Unavailable).WithDetails(retry),
+			err:     ErrNotEq,
+			fail:    true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.matcher.Match(tc.err)
+			if got, exp := err != nil, tc.fail; got != exp {
+				t.Fatalf("Unexpecte error: %v", err)
+			}
+		})
+	}
+}
@@ -1,11 +1,54 @@
 package match
 
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
 type Matcher interface {
 	Match(error, interface{}) error
 	Validate() error
 }
 
+// ReceiveMatcher is a matcher accepted by port Receive. Custom matchers
+// implementing it can be passed to Receive like the matchers of this package.
+type ReceiveMatcher interface {
+	// MatchReceived matches received message got or receive error err
+	// returned instead of the message, like grpc error response.
+	MatchReceived(err error, got interface{}) error
+	// FailureMessage renders test failure message of MatchReceived error.
+	FailureMessage(got interface{}, err error) string
+}
+
 var (
 	_ Matcher = (*PayloadMatcher)(nil)
 	_ Matcher = (*FnType)(nil)
+
+	_ ReceiveMatcher = (*TypeT)(nil)
+	_ ReceiveMatcher = (*DeepEqualType)(nil)
+	_ ReceiveMatcher = (*DiffType)(nil)
+	_ ReceiveMatcher = (*ProtoEqualType)(nil)
+	_ ReceiveMatcher = (*FnType)(nil)
+	_ ReceiveMatcher = (*PayloadMatcher)(nil)
+	_ ReceiveMatcher = (*GRPCErrType)(nil)
+	_ ReceiveMatcher = (*ProtoDiffType)(nil)
+	_ ReceiveMatcher = (*CaptureType)(nil)
+	_ ReceiveMatcher = (*BodyType)(nil)
+	_ ReceiveMatcher = (*AllType)(nil)
+	_ ReceiveMatcher = (*AnyType)(nil)
+	_ ReceiveMatcher = (*NotType)(nil)
+	_ ReceiveMatcher = (*FieldType)(nil)
+	_ ReceiveMatcher = (*PredicateType)(nil)
+	_ ReceiveMatcher = (*GoldenType)(nil)
 )
+
+// errReceived is returned by message matchers when error was received
+// instead of a message.
+func errReceived(err error) error {
+	return errors.Wrapf(err, "received error instead of message")
+}
+
+func failureMessage(exp interface{}, err error) string {
+	return fmt.Sprintf("Failed to receive %T:\n %v", exp, err)
+}
@@ -0,0 +1,81 @@
+package match
+
+import (
+	"testing"
+
+	pb "github.com/golang/protobuf/proto/proto3_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMatchReceived(t *testing.T) {
+	msg := &pb.Message{
+		Name: "message",
+	}
+	grpcErr := status.Error(codes.NotFound, "not found")
+
+	cases := []struct {
+		name    string
+		matcher ReceiveMatcher
+		got     interface{}
+		err     error
+		fail    bool
+	}{
+		{
+			name:    "deep equal",
+			matcher: DeepEqual(&pb.Message{Name: "message"}),
+			got:     msg,
+		},
+		{
+			name:    "deep equal received error",
+			matcher: DeepEqual(&pb.Message{Name: "message"}),
+			err:     grpcErr,
+			fail:    true,
+		},
+		{
+			name:    "type",
+			matcher: Type(&pb.Message{}),
+			got:     msg,
+		},
+		{
+			name:    "proto equal",
+			matcher: ProtoEqual(&pb.Message{Name: "other"}),
+			got:     msg,
+			fail:    true,
+		},
+		{
+			name:    "diff",
+			matcher: Diff(&pb.Message{Name: "message"}),
+			got:     msg,
+		},
+		{
+			name:    "fn received error",
+			matcher: Fn(func(*pb.Message) {}),
+			err:     grpcErr,
+			fail:    true,
+		},
+		{
+			name:    "grpc error",
+			matcher: GRPCStatusCode(codes.NotFound),
+			err:     grpcErr,
+		},
+		{
+			name:    "grpc error received message",
+			matcher: GRPCStatusCode(codes.NotFound),
+			got:     msg,
+			fail:    true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.matcher.MatchReceived(tc.err, tc.got)
+			if got, exp := err != nil, tc.fail; got != exp {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if err != nil && tc.matcher.FailureMessage(tc.got, err) == "" {
+				t.Fatalf("Empty failure message")
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package match
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+// pathStep is a single step of field path or JSONPath, either a field name
+// or map key, or a repeated field index.
+type pathStep struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+func (s pathStep) String() string {
+	if s.isIndex {
+		return "[" + strconv.Itoa(s.index) + "]"
+	}
+	return s.name
+}
+
+func isJSONPath(path string) bool {
+	return strings.HasPrefix(path, "$")
+}
+
+// parsePath parses field path like `items[0].labels["env"]` or JSONPath
+// like `$.items[0].id`. Only child and index selectors are supported.
+func parsePath(path string) ([]pathStep, error) {
+	var steps []pathStep
+	s := strings.TrimPrefix(path, "$")
+	if !isJSONPath(path) && s != "" && s[0] != '[' {
+		s = "." + s
+	}
+	for s != "" {
+		switch s[0] {
+		case '.':
+			end := strings.IndexAny(s[1:], ".[") + 1
+			if end == 0 {
+				end = len(s)
+			}
+			if end == 1 {
+				return nil, errors.Errorf("invalid path %q: empty field name", path)
+			}
+			steps = append(steps, pathStep{name: s[1:end]})
+			s = s[end:]
+		case '[':
+			end := strings.Index(s, "]")
+			if end == -1 {
+				return nil, errors.Errorf("invalid path %q: missing ']'", path)
+			}
+			sel := s[1:end]
+			s = s[end+1:]
+			if n, err := strconv.Atoi(sel); err == nil {
+				steps = append(steps, pathStep{index: n, isIndex: true})
+				continue
+			}
+			if len(sel) < 2 || (sel[0] != '"' && sel[0] != '\'') || sel[len(sel)-1] != sel[0] {
+				return nil, errors.Errorf("invalid path %q: unsupported selector [%s]", path, sel)
+			}
+			steps = append(steps, pathStep{name: sel[1 : len(sel)-1]})
+		default:
+			return nil, errors.Errorf("invalid path %q", path)
+		}
+	}
+	return steps, nil
+}
+
+// lookupPath returns value of message i at field path or JSONPath.
+func lookupPath(i interface{}, path string) (interface{}, error) {
+	steps, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if !isJSONPath(path) {
+		v, err := lookupField(reflect.ValueOf(i), steps)
+		if err != nil {
+			return nil, errors.Wrapf(err, "path %q", path)
+		}
+		return v.Interface(), nil
+	}
+
+	doc, err := jsonDocument(i)
+	if err != nil {
+		return nil, err
+	}
+	v, err := lookupJSON(doc, steps)
+	if err != nil {
+		return nil, errors.Wrapf(err, "path %q", path)
+	}
+	return v, nil
+}
+
+func lookupField(v reflect.Value, steps []pathStep) (reflect.Value, error) {
+	for _, step := range steps {
+		v = normalize(v)
+		for v.IsValid() && v.Kind() == reflect.Ptr && !v.IsNil() {
+			v = normalize(v.Elem())
+		}
+		if !v.IsValid() || v.Kind() == reflect.Ptr {
+			return reflect.Value{}, errors.Errorf("%v: nil value", step)
+		}
+
+		switch {
+		case step.isIndex && v.Kind() == reflect.Slice:
+			if step.index < 0 || step.index >= v.Len() {
+				return reflect.Value{}, errors.Errorf("%v: index out of range, len %d", step, v.Len())
+			}
+			v = v.Index(step.index)
+		case !step.isIndex && v.Kind() == reflect.Struct:
+			f, ok := structField(v, step.name)
+			if !ok {
+				return reflect.Value{}, errors.Errorf("%v: field not found in %v", step, v.Type())
+			}
+			v = f
+		case !step.isIndex && v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+			f := v.MapIndex(reflect.ValueOf(step.name).Convert(v.Type().Key()))
+			if !f.IsValid() {
+				return reflect.Value{}, errors.Errorf("%v: key not found", step)
+			}
+			v = f
+		default:
+			return reflect.Value{}, errors.Errorf("%v: can't select from %v", step, v.Type())
+		}
+	}
+	v = normalize(v)
+	if !v.IsValid() {
+		return reflect.Value{}, errors.Errorf("nil value")
+	}
+	return v, nil
+}
+
+// structField finds struct field by proto or go name, fields of oneof
+// wrappers are looked up too.
+func structField(v reflect.Value, name string) (reflect.Value, bool) {
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		if _, ok := sf.Tag.Lookup("protobuf_oneof"); ok {
+			w := normalize(v.Field(i))
+			if w.IsValid() && w.Kind() == reflect.Ptr && !w.IsNil() {
+				if f, ok := structField(w.Elem(), name); ok {
+					return f, true
+				}
+			}
+			continue
+		}
+		if fieldName(sf) == name || sf.Name == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func lookupJSON(doc interface{}, steps []pathStep) (interface{}, error) {
+	for _, step := range steps {
+		switch t := doc.(type) {
+		case []interface{}:
+			if !step.isIndex {
+				return nil, errors.Errorf("%v: can't select field from array", step)
+			}
+			if step.index < 0 || step.index >= len(t) {
+				return nil, errors.Errorf("%v: index out of range, len %d", step, len(t))
+			}
+			doc = t[step.index]
+		case map[string]interface{}:
+			if step.isIndex {
+				return nil, errors.Errorf("%v: can't index object", step)
+			}
+			v, ok := t[step.name]
+			if !ok {
+				return nil, errors.Errorf("%v: key not found", step)
+			}
+			doc = v
+		default:
+			return nil, errors.Errorf("%v: can't select from %T", step, doc)
+		}
+	}
+	return doc, nil
+}
+
+// jsonDocument decodes JSON document of message i. Raw JSON is decoded
+// as is, proto messages are marshaled with proto field names and structs
+// with Body or Content field, like port.HTTPRequest, are decoded from
+// the body.
+func jsonDocument(i interface{}) (interface{}, error) {
+	var buf []byte
+	switch t := i.(type) {
+	case []byte:
+		buf = t
+	case string:
+		buf = []byte(t)
+	case proto.Message:
+		var b bytes.Buffer
+		m := jsonpb.Marshaler{OrigName: true}
+		if err := m.Marshal(&b, t); err != nil {
+			return nil, errors.Wrapf(err, "failed to marshal %T to json", i)
+		}
+		buf = b.Bytes()
+	default:
+		if body, ok := bodyField(i); ok {
+			buf = body
+			break
+		}
+		b, err := json.Marshal(i)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to marshal %T to json", i)
+		}
+		buf = b
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(buf, &doc); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode json of %T", i)
+	}
+	return doc, nil
+}
+
+// bodyField returns Body or Content bytes field of struct i.
+func bodyField(i interface{}) ([]byte, bool) {
+	v := reflect.ValueOf(i)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, false
+	}
+	for _, name := range []string{"Body", "Content"} {
+		if f := v.FieldByName(name); f.IsValid() && f.Type() == reflect.TypeOf([]byte(nil)) {
+			return f.Bytes(), true
+		}
+	}
+	return nil, false
+}
@@ -31,3 +31,14 @@ func (m *PayloadMatcher) Match(err error, got interface{}) error {
 	}
 	return nil
 }
+
+func (m *PayloadMatcher) MatchReceived(err error, got interface{}) error {
+	if err != nil {
+		return errReceived(err)
+	}
+	return m.Match(nil, got)
+}
+
+func (m *PayloadMatcher) FailureMessage(got interface{}, err error) string {
+	return failureMessage(m.Exp, err)
+}
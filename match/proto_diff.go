@@ -0,0 +1,387 @@
+package match
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/pkg/errors"
+)
+
+type ProtoDiffType struct {
+	exp    interface{}
+	differ differ
+}
+
+type DiffOption func(*differ)
+
+// IgnoreFields skips fields with the given paths, like "id" or
+// "items.created_at". Repeated field and map indexes are not part of the path.
+func IgnoreFields(paths ...string) DiffOption {
+	return func(d *differ) {
+		if d.ignore == nil {
+			d.ignore = make(map[string]bool)
+		}
+		for _, p := range paths {
+			d.ignore[p] = true
+		}
+	}
+}
+
+// IgnoreRepeatedOrder compares repeated fields as unordered collections.
+func IgnoreRepeatedOrder() DiffOption {
+	return func(d *differ) {
+		d.unordered = true
+	}
+}
+
+// FloatTolerance treats float fields as equal if they differ by at most margin.
+func FloatTolerance(margin float64) DiffOption {
+	return func(d *differ) {
+		d.floatMargin = margin
+	}
+}
+
+// ProtoDiff matches proto messages and reports paths of differing fields
+// named by proto field names.
+func ProtoDiff(exp interface{}, opts ...DiffOption) *ProtoDiffType {
+	m := &ProtoDiffType{
+		exp: exp,
+	}
+	for _, o := range opts {
+		o(&m.differ)
+	}
+	return m
+}
+
+// Partial matches only fields set in expected proto message or struct,
+// other fields of received message are ignored. Maps are matched by
+// expected keys. Fields with zero values can't be matched by Partial.
+func Partial(exp interface{}, opts ...DiffOption) *ProtoDiffType {
+	m := ProtoDiff(exp, opts...)
+	m.differ.partial = true
+	return m
+}
+
+func (m *ProtoDiffType) Match(got interface{}) error {
+	exp, got := fromDynamic(m.exp, got), fromDynamic(got, m.exp)
+	diffs := m.differ.diff("", reflect.ValueOf(exp), reflect.ValueOf(got))
+	if len(diffs) == 0 {
+		return nil
+	}
+	return errors.Wrapf(ErrNotEq, "proto diff:\n %s\n", strings.Join(diffs, "\n "))
+}
+
+func (m *ProtoDiffType) MatchReceived(err error, got interface{}) error {
+	if err != nil {
+		return errReceived(err)
+	}
+	return m.Match(got)
+}
+
+func (m *ProtoDiffType) FailureMessage(got interface{}, err error) string {
+	return failureMessage(m.exp, err)
+}
+
+// differ walks expected and received values and collects differences
+// of fields.
+type differ struct {
+	ignore      map[string]bool
+	unordered   bool
+	floatMargin float64
+	// partial skips fields not set in expected value.
+	partial bool
+}
+
+// fieldMap holds dynamic message fields by proto field name.
+type fieldMap map[string]interface{}
+
+var (
+	dynamicType  = reflect.TypeOf((*dynamic.Message)(nil))
+	fieldMapType = reflect.TypeOf(fieldMap(nil))
+	indexRe      = regexp.MustCompile(`\[[^\]]*\]`)
+)
+
+func (d *differ) ignored(path string) bool {
+	return d.ignore[indexRe.ReplaceAllString(path, "")]
+}
+
+func (d *differ) diff(path string, exp, got reflect.Value) []string {
+	exp, got = normalize(exp), normalize(got)
+	if !exp.IsValid() || !got.IsValid() {
+		if exp.IsValid() == got.IsValid() {
+			return nil
+		}
+		return []string{diffLine(path, exp, got)}
+	}
+	if exp.Type() != got.Type() {
+		return []string{fmt.Sprintf("%s: got type %v, want %v", pathName(path), got.Type(), exp.Type())}
+	}
+
+	switch exp.Kind() {
+	case reflect.Ptr:
+		if exp.IsNil() || got.IsNil() {
+			if exp.IsNil() == got.IsNil() {
+				return nil
+			}
+			return []string{diffLine(path, exp, got)}
+		}
+		return d.diff(path, exp.Elem(), got.Elem())
+	case reflect.Struct:
+		return d.diffStruct(path, exp, got)
+	case reflect.Slice:
+		if exp.Type().Elem().Kind() == reflect.Uint8 {
+			if bytes.Equal(exp.Bytes(), got.Bytes()) {
+				return nil
+			}
+			return []string{diffLine(path, exp, got)}
+		}
+		if d.unordered {
+			return d.diffUnordered(path, exp, got)
+		}
+		return d.diffSlice(path, exp, got)
+	case reflect.Map:
+		return d.diffMap(path, exp, got)
+	case reflect.Float32, reflect.Float64:
+		if math.Abs(exp.Float()-got.Float()) <= d.floatMargin {
+			return nil
+		}
+		return []string{diffLine(path, exp, got)}
+	default:
+		if reflect.DeepEqual(exp.Interface(), got.Interface()) {
+			return nil
+		}
+		return []string{diffLine(path, exp, got)}
+	}
+}
+
+func (d *differ) diffStruct(path string, exp, got reflect.Value) []string {
+	if eq, ok := opaqueEqual(exp, got); ok {
+		if eq {
+			return nil
+		}
+		return []string{diffLine(path, exp, got)}
+	}
+	var diffs []string
+	for i := 0; i < exp.NumField(); i++ {
+		sf := exp.Type().Field(i)
+		if sf.PkgPath != "" || strings.HasPrefix(sf.Name, "XXX_") {
+			continue
+		}
+		p := path
+		// Oneof wrapper struct holds the field name.
+		if _, ok := sf.Tag.Lookup("protobuf_oneof"); !ok {
+			p = joinPath(path, fieldName(sf))
+		}
+		if d.ignored(p) || d.partial && isUnset(exp.Field(i)) {
+			continue
+		}
+		diffs = append(diffs, d.diff(p, exp.Field(i), got.Field(i))...)
+	}
+	return diffs
+}
+
+// opaqueEqual compares structs which can't be compared field by field,
+// like time.Time or big.Int. Structs with Equal method are compared by it,
+// structs without exported fields by DeepEqual. ok is false for other structs.
+func opaqueEqual(exp, got reflect.Value) (eq, ok bool) {
+	t := exp.Type()
+	if m, found := t.MethodByName("Equal"); found {
+		mt := m.Type
+		if mt.NumIn() == 2 && mt.In(1) == t && mt.NumOut() == 1 && mt.Out(0).Kind() == reflect.Bool {
+			return m.Func.Call([]reflect.Value{exp, got})[0].Bool(), true
+		}
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath == "" {
+			return false, false
+		}
+	}
+	return reflect.DeepEqual(exp.Interface(), got.Interface()), true
+}
+
+func (d *differ) diffSlice(path string, exp, got reflect.Value) []string {
+	var diffs []string
+	for i := 0; i < exp.Len() || i < got.Len(); i++ {
+		p := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case i >= got.Len():
+			diffs = append(diffs, fmt.Sprintf("%s: missing %s", p, formatValue(exp.Index(i))))
+		case i >= exp.Len():
+			diffs = append(diffs, fmt.Sprintf("%s: unexpected %s", p, formatValue(got.Index(i))))
+		default:
+			diffs = append(diffs, d.diff(p, exp.Index(i), got.Index(i))...)
+		}
+	}
+	return diffs
+}
+
+func (d *differ) diffUnordered(path string, exp, got reflect.Value) []string {
+	var diffs []string
+	used := make([]bool, got.Len())
+	for i := 0; i < exp.Len(); i++ {
+		found := false
+		for j := 0; j < got.Len(); j++ {
+			if !used[j] && len(d.diff(path, exp.Index(i), got.Index(j))) == 0 {
+				used[j], found = true, true
+				break
+			}
+		}
+		if !found {
+			diffs = append(diffs, fmt.Sprintf("%s: missing %s", pathName(path), formatValue(exp.Index(i))))
+		}
+	}
+	for j, ok := range used {
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("%s: unexpected %s", pathName(path), formatValue(got.Index(j))))
+		}
+	}
+	return diffs
+}
+
+func (d *differ) diffMap(path string, exp, got reflect.Value) []string {
+	keys := exp.MapKeys()
+	for _, k := range got.MapKeys() {
+		if !d.partial && !exp.MapIndex(k).IsValid() {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+
+	var diffs []string
+	for _, k := range keys {
+		p := fmt.Sprintf("%s[%q]", path, fmt.Sprint(k.Interface()))
+		if exp.Type() == fieldMapType {
+			p = joinPath(path, k.String())
+		}
+		ev, gv := exp.MapIndex(k), got.MapIndex(k)
+		if d.ignored(p) || d.partial && exp.Type() == fieldMapType && isUnset(ev) {
+			continue
+		}
+		switch {
+		case !gv.IsValid():
+			diffs = append(diffs, fmt.Sprintf("%s: missing %s", p, formatValue(ev)))
+		case !ev.IsValid():
+			diffs = append(diffs, fmt.Sprintf("%s: unexpected %s", p, formatValue(gv)))
+		default:
+			diffs = append(diffs, d.diff(p, ev, gv)...)
+		}
+	}
+	return diffs
+}
+
+// isUnset reports whether v is zero value or empty repeated field.
+func isUnset(v reflect.Value) bool {
+	v = normalize(v)
+	if !v.IsValid() {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// normalize unwraps interfaces and converts dynamic messages to fieldMap.
+func normalize(v reflect.Value) reflect.Value {
+	for v.IsValid() && v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if v.IsValid() && v.Type() == dynamicType && !v.IsNil() {
+		return reflect.ValueOf(toFieldMap(v.Interface().(*dynamic.Message)))
+	}
+	return v
+}
+
+func toFieldMap(msg *dynamic.Message) fieldMap {
+	fields := make(fieldMap)
+	for _, fd := range msg.GetMessageDescriptor().GetFields() {
+		fields[fd.GetName()] = msg.GetField(fd)
+	}
+	return fields
+}
+
+// fromDynamic converts dynamic message i to the generated message type
+// of other, so messages can be compared field by field.
+func fromDynamic(i, other interface{}) interface{} {
+	dm, ok := i.(*dynamic.Message)
+	if !ok || isDynamic(other) {
+		return i
+	}
+	t := reflect.TypeOf(other)
+	if _, ok := other.(proto.Message); !ok || t.Kind() != reflect.Ptr {
+		return i
+	}
+	msg := reflect.New(t.Elem()).Interface().(proto.Message)
+	if err := dm.ConvertTo(msg); err != nil {
+		return i
+	}
+	return msg
+}
+
+// fieldName returns proto field name of generated message field or go
+// name of other struct fields.
+func fieldName(sf reflect.StructField) string {
+	for _, p := range strings.Split(sf.Tag.Get("protobuf"), ",") {
+		if strings.HasPrefix(p, "name=") {
+			return strings.TrimPrefix(p, "name=")
+		}
+	}
+	return sf.Name
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func pathName(path string) string {
+	if path == "" {
+		return "message"
+	}
+	return path
+}
+
+func diffLine(path string, exp, got reflect.Value) string {
+	return fmt.Sprintf("%s: got %s, want %s", pathName(path), formatValue(got), formatValue(exp))
+}
+
+func formatValue(v reflect.Value) string {
+	v = normalize(v)
+	if !v.IsValid() {
+		return "<nil>"
+	}
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return "<nil>"
+	}
+	if v.Kind() == reflect.Struct && v.CanAddr() {
+		switch t := v.Addr().Interface().(type) {
+		case proto.Message:
+			return "{" + proto.CompactTextString(t) + "}"
+		case fmt.Stringer:
+			// Pointer receiver String, like big.Int one.
+			return t.String()
+		}
+	}
+	switch t := v.Interface().(type) {
+	case proto.Message:
+		return "{" + proto.CompactTextString(t) + "}"
+	case string:
+		return fmt.Sprintf("%q", t)
+	case []byte:
+		return fmt.Sprintf("%q", t)
+	default:
+		return fmt.Sprintf("%+v", t)
+	}
+}
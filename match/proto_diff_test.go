@@ -0,0 +1,255 @@
+package match
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/golang/protobuf/proto/proto3_proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+)
+
+func TestProtoDiff(t *testing.T) {
+	got := &pb.Message{
+		Name:        "message",
+		ResultCount: 42,
+		Score:       1.0001,
+		Key:         []uint64{3, 1, 2},
+		Nested: &pb.Nested{
+			Bunny: "volatile",
+			Cute:  true,
+		},
+		Terrain: map[string]*pb.Nested{
+			"north": {Bunny: "n"},
+		},
+		Children: []*pb.Message{
+			{Name: "first", ResultCount: 1},
+			{Name: "second", ResultCount: 2},
+		},
+	}
+
+	cases := []struct {
+		name    string
+		matcher *ProtoDiffType
+		diffs   []string
+	}{
+		{
+			name: "equal",
+			matcher: ProtoDiff(&pb.Message{
+				Name:        "message",
+				ResultCount: 42,
+				Score:       1.0001,
+				Key:         []uint64{3, 1, 2},
+				Nested:      &pb.Nested{Bunny: "volatile", Cute: true},
+				Terrain:     map[string]*pb.Nested{"north": {Bunny: "n"}},
+				Children:    []*pb.Message{{Name: "first", ResultCount: 1}, {Name: "second", ResultCount: 2}},
+			}),
+		},
+		{
+			name: "field paths",
+			matcher: ProtoDiff(&pb.Message{
+				Name:        "message",
+				ResultCount: 43,
+				Score:       1.0001,
+				Key:         []uint64{3, 1, 2},
+				Nested:      &pb.Nested{Bunny: "other", Cute: true},
+				Terrain:     map[string]*pb.Nested{"south": {Bunny: "n"}},
+				Children:    []*pb.Message{{Name: "first", ResultCount: 1}, {Name: "second", ResultCount: 3}},
+			}),
+			diffs: []string{
+				"result_count: got 42, want 43",
+				`nested.bunny: got "volatile", want "other"`,
+				`terrain["north"]: unexpected {bunny:"n" }`,
+				`terrain["south"]: missing {bunny:"n" }`,
+				"children[1].result_count: got 2, want 3",
+			},
+		},
+		{
+			name: "ignore fields",
+			matcher: ProtoDiff(&pb.Message{
+				Name:     "message",
+				Score:    1.0001,
+				Key:      []uint64{3, 1, 2},
+				Nested:   &pb.Nested{Cute: true},
+				Terrain:  map[string]*pb.Nested{"north": {Bunny: "n"}},
+				Children: []*pb.Message{{Name: "first"}, {Name: "second"}},
+			}, IgnoreFields("result_count", "nested.bunny", "children.result_count")),
+		},
+		{
+			name: "repeated order",
+			matcher: ProtoDiff(&pb.Message{
+				Name:        "message",
+				ResultCount: 42,
+				Score:       1.0001,
+				Key:         []uint64{1, 2, 3},
+				Nested:      &pb.Nested{Bunny: "volatile", Cute: true},
+				Terrain:     map[string]*pb.Nested{"north": {Bunny: "n"}},
+				Children:    []*pb.Message{{Name: "second", ResultCount: 2}, {Name: "first", ResultCount: 1}},
+			}),
+			diffs: []string{
+				"key[0]: got 3, want 1",
+				"children[0].name",
+			},
+		},
+		{
+			name: "ignore repeated order",
+			matcher: ProtoDiff(&pb.Message{
+				Name:        "message",
+				ResultCount: 42,
+				Score:       1.0001,
+				Key:         []uint64{1, 2, 3},
+				Nested:      &pb.Nested{Bunny: "volatile", Cute: true},
+				Terrain:     map[string]*pb.Nested{"north": {Bunny: "n"}},
+				Children:    []*pb.Message{{Name: "second", ResultCount: 2}, {Name: "first", ResultCount: 1}},
+			}, IgnoreRepeatedOrder()),
+		},
+		{
+			name: "float tolerance",
+			matcher: ProtoDiff(&pb.Message{
+				Name:        "message",
+				ResultCount: 42,
+				Score:       1,
+				Key:         []uint64{3, 1, 2},
+				Nested:      &pb.Nested{Bunny: "volatile", Cute: true},
+				Terrain:     map[string]*pb.Nested{"north": {Bunny: "n"}},
+				Children:    []*pb.Message{{Name: "first", ResultCount: 1}, {Name: "second", ResultCount: 2}},
+			}, FloatTolerance(0.001)),
+		},
+		{
+			name: "float without tolerance",
+			matcher: ProtoDiff(&pb.Message{
+				Name:        "message",
+				ResultCount: 42,
+				Score:       1,
+				Key:         []uint64{3, 1, 2},
+				Nested:      &pb.Nested{Bunny: "volatile", Cute: true},
+				Terrain:     map[string]*pb.Nested{"north": {Bunny: "n"}},
+				Children:    []*pb.Message{{Name: "first", ResultCount: 1}, {Name: "second", ResultCount: 2}},
+			}),
+			diffs: []string{
+				"score: got 1.0001, want 1",
+			},
+		},
+		{
+			name: "partial",
+			matcher: Partial(&pb.Message{
+				Name:     "message",
+				Nested:   &pb.Nested{Cute: true},
+				Terrain:  map[string]*pb.Nested{"north": {}},
+				Children: []*pb.Message{{Name: "first"}, {ResultCount: 2}},
+			}),
+		},
+		{
+			name: "partial mismatch",
+			matcher: Partial(&pb.Message{
+				Nested:   &pb.Nested{Bunny: "other"},
+				Terrain:  map[string]*pb.Nested{"south": {}},
+				Children: []*pb.Message{{Name: "first"}},
+			}),
+			diffs: []string{
+				`nested.bunny: got "volatile", want "other"`,
+				`terrain["south"]: missing {}`,
+				`children[1]: unexpected {name:"second" result_count:2 }`,
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.matcher.Match(got)
+			if got, exp := err != nil, len(tc.diffs) > 0; got != exp {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for _, d := range tc.diffs {
+				if !strings.Contains(err.Error(), d) {
+					t.Fatalf("Diff %q not found in:\n%v", d, err)
+				}
+			}
+		})
+	}
+}
+
+func TestProtoDiffDynamic(t *testing.T) {
+	md, err := desc.LoadMessageDescriptorForMessage((*pb.Message)(nil))
+	if err != nil {
+		t.Fatalf("failed to load message descriptor: %v", err)
+	}
+	dm := dynamic.NewMessage(md)
+	if err := dm.UnmarshalText([]byte(`name: "42" nested: { bunny: "b" }`)); err != nil {
+		t.Fatalf("failed to unmarshal dynamic message: %v", err)
+	}
+	other := dynamic.NewMessage(md)
+	if err := other.UnmarshalText([]byte(`name: "42" nested: { bunny: "c" }`)); err != nil {
+		t.Fatalf("failed to unmarshal dynamic message: %v", err)
+	}
+
+	if err := ProtoDiff(dm).Match(&pb.Message{Name: "42", Nested: &pb.Nested{Bunny: "b"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	err = ProtoDiff(dm).Match(other)
+	if err == nil || !strings.Contains(err.Error(), `nested.bunny: got "c", want "b"`) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestPartialStruct(t *testing.T) {
+	type request struct {
+		Method string
+		Header map[string][]string
+		Body   []byte
+	}
+	got := &request{
+		Method: "POST",
+		Header: map[string][]string{"Content-Type": {"application/json"}, "X-Id": {"1"}},
+		Body:   []byte("body"),
+	}
+
+	if err := Partial(&request{Header: map[string][]string{"X-Id": {"1"}}}).Match(got); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	err := Partial(&request{Method: "GET"}).Match(got)
+	if err == nil || !strings.Contains(err.Error(), `Method: got "POST", want "GET"`) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestPartialStructTime(t *testing.T) {
+	type event struct {
+		Name string
+		At   time.Time
+	}
+	got := &event{Name: "tick", At: time.Unix(99999, 0)}
+
+	if err := Partial(&event{At: time.Unix(99999, 0)}).Match(got); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := Partial(&event{Name: "tick"}).Match(got); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	err := Partial(&event{At: time.Unix(1, 0)}).Match(got)
+	if err == nil || !strings.Contains(err.Error(), "At: got") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestProtoDiffOpaqueStruct(t *testing.T) {
+	type event struct {
+		At time.Time
+		N  *big.Int
+	}
+	exp := &event{At: time.Unix(1, 0), N: big.NewInt(1)}
+
+	if err := ProtoDiff(exp).Match(&event{At: time.Unix(1, 0).UTC(), N: big.NewInt(1)}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	err := ProtoDiff(exp).Match(&event{At: time.Unix(99999, 0), N: big.NewInt(1)})
+	if err == nil || !strings.Contains(err.Error(), "At: got") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	err = ProtoDiff(exp).Match(&event{At: time.Unix(1, 0), N: big.NewInt(2)})
+	if err == nil || !strings.Contains(err.Error(), "N: got 2, want 1") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/dynamic"
 	"github.com/pkg/errors"
 )
 
@@ -27,8 +28,26 @@ func (m *ProtoEqualType) Match(got interface{}) error {
 	if !ok {
 		return fmt.Errorf("%T is not proto message", got)
 	}
-	if !proto.Equal(gotP, expP) {
+	if !dynamic.MessagesEqual(gotP, expP) {
 		return errors.Wrapf(ErrNotEq, "deep equal: \n got: '%v'\n exp: '%v'\n", gotP.String(), expP.String())
 	}
 	return nil
 }
+
+// isDynamic reports whether i is a dynamic message, such messages are
+// compared by proto semantic instead of go values.
+func isDynamic(i interface{}) bool {
+	_, ok := i.(*dynamic.Message)
+	return ok
+}
+
+func (m *ProtoEqualType) MatchReceived(err error, got interface{}) error {
+	if err != nil {
+		return errReceived(err)
+	}
+	return m.Match(got)
+}
+
+func (m *ProtoEqualType) FailureMessage(got interface{}, err error) string {
+	return failureMessage(m.exp, err)
+}
@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	pb "github.com/golang/protobuf/proto/proto3_proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
 	"github.com/pkg/errors"
 )
 
@@ -45,3 +47,22 @@ func TestProtoEqual(t *testing.T) {
 		})
 	}
 }
+
+func TestProtoEqualDynamic(t *testing.T) {
+	md, err := desc.LoadMessageDescriptorForMessage((*pb.Message)(nil))
+	if err != nil {
+		t.Fatalf("failed to load message descriptor: %v", err)
+	}
+	dm := dynamic.NewMessage(md)
+	if err := dm.UnmarshalText([]byte(`name: "42"`)); err != nil {
+		t.Fatalf("failed to unmarshal dynamic message: %v", err)
+	}
+
+	if err := DeepEqual(dm).Match(&pb.Message{Name: "42"}); err != nil {
+		t.Fatalf("Unexpecte error: %v", err)
+	}
+	err = ProtoEqual(dm).Match(&pb.Message{Name: "24"})
+	if errors.Cause(err) != ErrNotEq {
+		t.Fatalf("Unexpecte error: %v", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
@@ -21,6 +22,9 @@ import (
 var (
 	ServerCertFile = "/tmp/mtf/cert/server.crt"
 	ServerKeyFile  = "/tmp/mtf/cert/server.key"
+
+	ClientCertFile = "/tmp/mtf/cert/client.crt"
+	ClientKeyFile  = "/tmp/mtf/cert/client.key"
 )
 
 var sniHosts = []string{
@@ -62,7 +66,7 @@ func GenCert(hosts []string) (*CertKey, error) {
 		NotAfter:  now.Add(365 * 24 * time.Hour),
 
 		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
 		BasicConstraintsValid: true,
 	}
 
@@ -83,6 +87,71 @@ func GenCert(hosts []string) (*CertKey, error) {
 		return nil, fmt.Errorf("Failed to create certificate: %s", err)
 	}
 
+	cert, err := encode(derBytes, priv)
+	if err != nil {
+		return nil, err
+	}
+	if err := WriteCert(cert); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// GenClientCert issues client certificate signed by the CA certificate
+// generated by GenCert. The commonName identifies the client on the
+// server side.
+func GenClientCert(commonName string) (*CertKey, error) {
+	ca, err := tls.LoadX509KeyPair(ServerCertFile, ServerKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load CA cert: %s", err)
+	}
+	caCert, err := x509.ParseCertificate(ca.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA cert: %s", err)
+	}
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate private key: %s", err)
+	}
+
+	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
+	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %s", err)
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			Organization: []string{"Acme"},
+			CommonName:   commonName,
+		},
+		NotBefore: now,
+		NotAfter:  now.Add(365 * 24 * time.Hour),
+
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+
+	derBytes, err := x509.CreateCertificate(rand.Reader, &template, caCert, publicKey(priv), ca.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create certificate: %s", err)
+	}
+
+	cert, err := encode(derBytes, priv)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFiles(ClientCertFile, ClientKeyFile, cert); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+func encode(derBytes []byte, priv interface{}) (*CertKey, error) {
 	var certBuff bytes.Buffer
 	if err := pem.Encode(&certBuff, &pem.Block{Type: "CERTIFICATE", Bytes: derBytes}); err != nil {
 		return nil, fmt.Errorf("failed to write data to cert.pem: %s", err)
@@ -93,27 +162,26 @@ func GenCert(hosts []string) (*CertKey, error) {
 		return nil, fmt.Errorf("failed to write data to key.pem: %s", err)
 	}
 
-	cert := &CertKey{
+	return &CertKey{
 		Cert: certBuff.Bytes(),
 		Key:  keyBuff.Bytes(),
-	}
-
-	if err := WriteCert(cert); err != nil {
-		return nil, err
-	}
-	return cert, nil
+	}, nil
 }
 
 func WriteCert(ck *CertKey) error {
-	dir := filepath.Dir(ServerCertFile)
+	return writeFiles(ServerCertFile, ServerKeyFile, ck)
+}
+
+func writeFiles(certFile, keyFile string, ck *CertKey) error {
+	dir := filepath.Dir(certFile)
 	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
 		return err
 	}
 
-	if err := ioutil.WriteFile(ServerCertFile, ck.Cert, 0665); err != nil {
+	if err := ioutil.WriteFile(certFile, ck.Cert, 0665); err != nil {
 		return err
 	}
-	if err := ioutil.WriteFile(ServerKeyFile, ck.Key, 0665); err != nil {
+	if err := ioutil.WriteFile(keyFile, ck.Key, 0665); err != nil {
 		return err
 	}
 	return nil
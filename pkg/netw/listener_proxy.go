@@ -31,8 +31,8 @@ func Listen(network, address string) (net.Listener, error) {
 }
 
 type listener struct {
-	l  net.Listener
-	wg sync.WaitGroup
+	l    net.Listener
+	once sync.Once
 }
 
 func (l *listener) Accept() (net.Conn, error) {
@@ -40,7 +40,8 @@ func (l *listener) Accept() (net.Conn, error) {
 	if err != nil {
 		return nil, err
 	}
-	defer startSync.Done()
+	// Only the first connection is reported, listener can serve many clients.
+	l.once.Do(startSync.Done)
 	return conn, nil
 }
 
@@ -0,0 +1,194 @@
+package port
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	mtfctx "github.com/smallinsky/mtf/framework/context"
+)
+
+// Expectation is a message expected on the port by ReceiveAll and
+// ReceiveAny, Msg is expected message or matcher accepted by Receive.
+type Expectation struct {
+	Port *Port
+	Msg  interface{}
+}
+
+// Expect returns expectation of message i received by port p.
+func Expect(p *Port, i interface{}) Expectation {
+	return Expectation{
+		Port: p,
+		Msg:  i,
+	}
+}
+
+// expectResult is the state of expectations checked by ReceiveAll and ReceiveAny.
+type expectResult struct {
+	mtx        sync.Mutex
+	calls      []*Call
+	satisfied  int
+	unexpected []string
+}
+
+// ReceiveAll waits for all expectations in any order and returns
+// received calls in the expectations order. The test fails if any
+// expectation wasn't satisfied within the port receive timeout.
+func ReceiveAll(t *testing.T, exps ...Expectation) []*Call {
+	res := receiveExpected(t, len(exps), exps)
+	if res.satisfied != len(exps) || len(res.unexpected) > 0 {
+		t.Fatalf("Failed to receive all messages:\n%s", res.report(exps))
+	}
+	return res.calls
+}
+
+// ReceiveAny waits for the first satisfied expectation and returns its
+// index and received call. The test fails if no expectation was
+// satisfied within the port receive timeout.
+func ReceiveAny(t *testing.T, exps ...Expectation) (int, *Call) {
+	res := receiveExpected(t, 1, exps)
+	if res.satisfied == 0 || len(res.unexpected) > 0 {
+		t.Fatalf("Failed to receive any message:\n%s", res.report(exps))
+	}
+	for i, call := range res.calls {
+		if call != nil {
+			return i, call
+		}
+	}
+	return -1, nil
+}
+
+// receiveExpected receives messages on all expectation ports
+// concurrently until n expectations are satisfied or timeout expires.
+func receiveExpected(t *testing.T, n int, exps []Expectation) *expectResult {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultPortOpts.timeout)
+	defer cancel()
+
+	res := &expectResult{
+		calls: make([]*Call, len(exps)),
+	}
+	ports := make(map[*Port][]int)
+	var order []*Port
+	for i, e := range exps {
+		if _, ok := ports[e.Port]; !ok {
+			order = append(order, e.Port)
+		}
+		ports[e.Port] = append(ports[e.Port], i)
+	}
+
+	var wg sync.WaitGroup
+	for _, p := range order {
+		wg.Add(1)
+		go func(p *Port, idx []int) {
+			defer wg.Done()
+			for len(idx) > 0 && ctx.Err() == nil {
+				m, err := p.receive(t, ctx)
+				if m == nil {
+					// Port receive timeout or done context.
+					continue
+				}
+
+				res.mtx.Lock()
+				if res.satisfied >= n || len(res.unexpected) > 0 {
+					// Message received concurrently with the last satisfied
+					// expectation or failure, put it back for next Receive.
+					if r, ok := p.impl.(requeuer); ok {
+						r.requeue(m, err)
+						res.mtx.Unlock()
+						return
+					}
+				}
+				call, ok := m.(*Call)
+				if !ok {
+					call = &Call{
+						Msg: m,
+					}
+				}
+				call.port = p
+				if mtfc := mtfctx.Get(t); mtfc != nil {
+					mtfc.LogReceive(getPortName(p.impl), call.Msg)
+				}
+
+				matched := -1
+				for j, i := range idx {
+					if receiveMatcher(exps[i].Msg).MatchReceived(err, call.Msg) == nil {
+						matched = j
+						res.calls[i] = call
+						res.satisfied++
+						break
+					}
+				}
+				if matched == -1 {
+					res.unexpected = append(res.unexpected, fmt.Sprintf("%s: %T %v", getPortName(p.impl), call.Msg, errOrMsg(call.Msg, err)))
+				} else {
+					idx = append(idx[:matched], idx[matched+1:]...)
+				}
+				if res.satisfied >= n || matched == -1 {
+					cancel()
+				}
+				res.mtx.Unlock()
+			}
+		}(p, ports[p])
+	}
+	wg.Wait()
+	return res
+}
+
+// requeuer is implemented by ports that can put received message back,
+// so it is returned again by the next receive call.
+type requeuer interface {
+	requeue(m interface{}, err error)
+}
+
+// pendingQueue holds requeued messages of ports without own queue, they
+// are received before new messages.
+type pendingQueue struct {
+	mtx  sync.Mutex
+	msgs []pendingMsg
+}
+
+type pendingMsg struct {
+	msg interface{}
+	err error
+}
+
+func (q *pendingQueue) push(m interface{}, err error) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	q.msgs = append([]pendingMsg{{msg: m, err: err}}, q.msgs...)
+}
+
+func (q *pendingQueue) pop() (pendingMsg, bool) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	if len(q.msgs) == 0 {
+		return pendingMsg{}, false
+	}
+	m := q.msgs[0]
+	q.msgs = q.msgs[1:]
+	return m, true
+}
+
+func errOrMsg(m interface{}, err error) interface{} {
+	if err != nil {
+		return err
+	}
+	return m
+}
+
+func (r *expectResult) report(exps []Expectation) string {
+	var lines []string
+	for i, e := range exps {
+		state := "timed out"
+		if r.calls[i] != nil {
+			state = "satisfied"
+		}
+		lines = append(lines, fmt.Sprintf(" %s: %s %T", state, getPortName(e.Port.impl), e.Msg))
+	}
+	for _, u := range r.unexpected {
+		lines = append(lines, " unexpected: "+u)
+	}
+	return strings.Join(lines, "\n")
+}
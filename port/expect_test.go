@@ -0,0 +1,124 @@
+package port
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallinsky/mtf/match"
+	"github.com/smallinsky/mtf/proto/oracle"
+)
+
+func TestReceiveAll(t *testing.T) {
+	genCerts(t)
+	svr, err := NewGRPCServerPort((*oracle.OracleServer)(nil), ":9983")
+	if err != nil {
+		t.Fatal("failed to create grpc server port: ", err)
+	}
+	client, err := NewGRPCClientPort((*oracle.OracleClient)(nil), "localhost:9983")
+	if err != nil {
+		t.Fatal("failed to create grpc client port: ", err)
+	}
+	httpPort := NewHTTPPort(WithHost("expect.example.com"))
+
+	get := func() {
+		req, _ := http.NewRequest(http.MethodGet, "http://localhost:8080/expect", nil)
+		req.Host = "expect.example.com"
+		if resp, err := (&http.Client{Timeout: time.Second}).Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}
+
+	t.Run("All", func(t *testing.T) {
+		go get()
+		client.Send(t, &oracle.AskDeepThoughtRequest{
+			Data: "first",
+		})
+		client.Send(t, &oracle.AskDeepThoughtRequest{
+			Data: "second",
+		})
+
+		calls := ReceiveAll(t,
+			Expect(svr, &oracle.AskDeepThoughtRequest{Data: "second"}),
+			Expect(httpPort, match.Type(&HTTPRequest{})),
+			Expect(svr, &oracle.AskDeepThoughtRequest{Data: "first"}),
+		)
+		if got, exp := calls[1].Msg.(*HTTPRequest).URL, "/expect"; got != exp {
+			t.Fatalf("Got: '%v' Expected: '%v'", got, exp)
+		}
+		calls[1].Send(t, &HTTPResponse{})
+		calls[0].Send(t, &oracle.AskDeepThoughtResponse{Data: "2"})
+		calls[2].Send(t, &oracle.AskDeepThoughtResponse{Data: "1"})
+		ReceiveAll(t,
+			Expect(client, &oracle.AskDeepThoughtResponse{Data: "1"}),
+			Expect(client, &oracle.AskDeepThoughtResponse{Data: "2"}),
+		)
+	})
+
+	t.Run("Any", func(t *testing.T) {
+		go get()
+		i, call := ReceiveAny(t,
+			Expect(svr, match.Type(&oracle.AskDeepThoughtRequest{})),
+			Expect(httpPort, match.Type(&HTTPRequest{})),
+		)
+		if i != 1 {
+			t.Fatalf("Got: '%v' Expected: '%v'", i, 1)
+		}
+		call.Send(t, &HTTPResponse{})
+	})
+
+}
+
+// barrierPort returns its message only when all ports sharing the
+// barrier are receiving, so all of them receive within one ReceiveAny.
+type barrierPort struct {
+	pendingQueue
+	msg     interface{}
+	barrier *sync.WaitGroup
+	once    sync.Once
+}
+
+func (p *barrierPort) Send(ctx context.Context, msg interface{}) error {
+	return nil
+}
+
+func (p *barrierPort) Receive(ctx context.Context) (interface{}, error) {
+	if m, ok := p.pop(); ok {
+		return m.msg, m.err
+	}
+	var msg interface{}
+	p.once.Do(func() {
+		p.barrier.Done()
+		p.barrier.Wait()
+		msg = p.msg
+	})
+	if msg == nil {
+		return nil, errors.New("no message")
+	}
+	return msg, nil
+}
+
+func (p *barrierPort) requeue(m interface{}, err error) {
+	p.push(m, err)
+}
+
+func TestReceiveAnyRequeue(t *testing.T) {
+	barrier := &sync.WaitGroup{}
+	barrier.Add(2)
+	ports := []*Port{
+		{impl: &barrierPort{msg: &HTTPRequest{URL: "/first"}, barrier: barrier}},
+		{impl: &barrierPort{msg: &HTTPRequest{URL: "/second"}, barrier: barrier}},
+	}
+	i, _ := ReceiveAny(t,
+		Expect(ports[0], match.Type(&HTTPRequest{})),
+		Expect(ports[1], match.Type(&HTTPRequest{})),
+	)
+
+	// Message received by the other port is returned by next Receive.
+	other := ports[1-i]
+	exp := other.impl.(*barrierPort).msg
+	other.Receive(t, exp)
+}
@@ -17,6 +17,8 @@ import (
 type FTPPort struct {
 	ftpEventC chan *pb.EventRequest
 	conn      *ftp.ServerConn
+	// queue holds requeued events received before new ones.
+	queue pendingQueue
 }
 
 func NewFTPPort(addr, user, pass string) (*Port, error) {
@@ -25,9 +27,7 @@ func NewFTPPort(addr, user, pass string) (*Port, error) {
 		return nil, err
 	}
 
-	return &Port{
-		impl: p,
-	}, nil
+	return newPort(p), nil
 }
 
 func NewFTP(addr, user, pass string) (*FTPPort, error) {
@@ -73,6 +73,9 @@ func (p *FTPPort) Send(ctx context.Context, i interface{}) error {
 }
 
 func (p *FTPPort) Receive(ctx context.Context) (interface{}, error) {
+	if m, ok := p.queue.pop(); ok {
+		return m.msg, m.err
+	}
 	select {
 	case msg := <-p.ftpEventC:
 		return &FTPEvent{
@@ -81,9 +84,15 @@ func (p *FTPPort) Receive(ctx context.Context) (interface{}, error) {
 		}, nil
 	case <-time.NewTimer(time.Second * 7).C:
 		return nil, errors.Errorf("failed to receive message, deadline exceeded")
+	case <-ctx.Done():
+		return nil, errors.Wrapf(ctx.Err(), "failed to receive message")
 	}
 }
 
+func (p *FTPPort) requeue(m interface{}, err error) {
+	p.queue.push(m, err)
+}
+
 func dialFTP(addr string, user, pass string) (*ftp.ServerConn, error) {
 	connection, err := ftp.Connect(addr)
 	if err != nil {
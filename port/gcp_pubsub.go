@@ -74,9 +74,7 @@ func NewPubsub(projectID, addr string) (*Port, error) {
 		}
 	}
 
-	return &Port{
-		impl: ps,
-	}, nil
+	return newPort(ps), nil
 }
 
 func customSubscriptionName(subscription *pubsub.Subscription) string {
@@ -115,13 +113,13 @@ func (ps *Pubsub) handle(ctx context.Context, msg *pubsub.Message) {
 
 type Pubsub struct {
 	messages chan proto.Message
-	queue    []proto.Message
+	queue    pendingQueue
 	topic    *pubsub.Topic
 	topicMap map[string]*pubsub.Topic
 }
 
 func (p *Pubsub) Receive(ctx context.Context) (interface{}, error) {
-	return p.receive()
+	return p.receive(withContext(ctx))
 }
 
 func (p *Pubsub) Send(ctx context.Context, i interface{}) error {
@@ -129,16 +127,30 @@ func (p *Pubsub) Send(ctx context.Context, i interface{}) error {
 }
 
 func (p *Pubsub) receive(opts ...Opt) (interface{}, error) {
+	options := defaultPortOpts
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if m, ok := p.queue.pop(); ok {
+		return m.msg, m.err
+	}
 	for {
 		select {
 		case msg := <-p.messages:
 			return msg, nil
 		case <-time.Tick(time.Second * 10):
 			return nil, fmt.Errorf("timout during pubsub.receive")
+		case <-options.done():
+			return nil, fmt.Errorf("failed to receive message: %v", options.ctx.Err())
 		}
 	}
 }
 
+func (p *Pubsub) requeue(m interface{}, err error) {
+	p.queue.push(m, err)
+}
+
 type PubSubSendRequest struct {
 	Topic   string
 	Message proto.Message
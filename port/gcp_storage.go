@@ -17,6 +17,8 @@ func NewGCStoragePort() *GCStorage {
 	return &GCStorage{
 		inEvent:  make(chan interface{}),
 		outEvent: make(chan interface{}),
+		stubs:    newStubSet(),
+		queue:    &pendingQueue{},
 	}
 }
 
@@ -30,6 +32,9 @@ func (s GCStorage) onObjectInsert(bo fakegcs.BucketObject, r io.Reader) error {
 		Object:  bo.Object,
 		Content: buff,
 	}
+	if _, ok := s.stubs.reply(req); ok {
+		return nil
+	}
 
 	select {
 	case s.inEvent <- req:
@@ -51,6 +56,9 @@ func (s GCStorage) onObjectGet(bo fakegcs.BucketObject, w io.Writer) error {
 		Bucket: bo.Bucket,
 		Object: bo.Object,
 	}
+	if msg, ok := s.stubs.reply(req); ok {
+		return writeStorageGetResponse(w, msg)
+	}
 
 	select {
 	case s.inEvent <- req:
@@ -61,14 +69,8 @@ func (s GCStorage) onObjectGet(bo fakegcs.BucketObject, w io.Writer) error {
 
 	select {
 	case msg := <-s.outEvent:
-		r, ok := msg.(*StorageGetResponse)
-		if !ok {
-			log.Fatalf("faield to receive event aa %T", msg)
-		}
-
-		_, err := io.Copy(w, bytes.NewReader(r.Content))
-		if err != nil {
-			log.Fatalf("faield to write content")
+		if err := writeStorageGetResponse(w, msg); err != nil {
+			log.Fatalf("faield to write content: %v", err)
 		}
 	case <-time.Tick(time.Second * 3):
 		log.Fatalf("gcs response not provided 4")
@@ -76,6 +78,15 @@ func (s GCStorage) onObjectGet(bo fakegcs.BucketObject, w io.Writer) error {
 	return nil
 }
 
+func writeStorageGetResponse(w io.Writer, msg interface{}) error {
+	r, ok := msg.(*StorageGetResponse)
+	if !ok {
+		return errors.Errorf("invalid storage get response type %T", msg)
+	}
+	_, err := io.Copy(w, bytes.NewReader(r.Content))
+	return err
+}
+
 func (s GCStorage) registerRouter(r *mux.Router) {
 	fgcs := &fakegcs.GCStorage{
 		OnObjectInsert: s.onObjectInsert,
@@ -87,6 +98,14 @@ func (s GCStorage) registerRouter(r *mux.Router) {
 type GCStorage struct {
 	inEvent  chan interface{}
 	outEvent chan interface{}
+	// queue holds requeued requests received before new ones.
+	queue *pendingQueue
+
+	stubs *stubSet
+}
+
+func (s *GCStorage) stubSet() *stubSet {
+	return s.stubs
 }
 
 type StorageInsertRequest struct {
@@ -108,14 +127,28 @@ type StorageGetResponse struct {
 }
 
 func (s *GCStorage) receive(opts ...Opt) (interface{}, error) {
+	options := defaultPortOpts
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if m, ok := s.queue.pop(); ok {
+		return m.msg, m.err
+	}
 	select {
 	case <-time.Tick(time.Second * 3):
 		return nil, errors.Errorf("failed to receive  message, deadline exceeded")
+	case <-options.done():
+		return nil, errors.Wrapf(options.ctx.Err(), "failed to receive message")
 	case msg := <-s.inEvent:
 		return msg, nil
 	}
 }
 
+func (s *GCStorage) requeue(m interface{}, err error) {
+	s.queue.push(m, err)
+}
+
 func (s *GCStorage) send(msg interface{}, opts ...PortOpt) error {
 	select {
 	case s.outEvent <- msg:
@@ -130,5 +163,5 @@ func (s *GCStorage) Send(ctx context.Context, i interface{}) error {
 }
 
 func (s *GCStorage) Receive(ctx context.Context) (interface{}, error) {
-	return s.receive()
+	return s.receive(withContext(ctx))
 }
@@ -2,44 +2,94 @@ package port
 
 import (
 	"context"
+	"io"
 	"reflect"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
 )
 
 type EndpointRespTypePair struct {
 	RespType reflect.Type
 	Endpoint string
+
+	ClientStream bool
+	ServerStream bool
+
+	// RespDesc is set for endpoints loaded from proto descriptors.
+	RespDesc *desc.MessageDescriptor
+
+	// reqKey is request go type or proto name of dynamic request.
+	reqKey string
+}
+
+// matchMethod reports whether endpoint is grpc method with the given name,
+// both "Method" and full "/package.Service/Method" names are accepted.
+func (e EndpointRespTypePair) matchMethod(name string) bool {
+	endpoint := strings.TrimPrefix(e.Endpoint, "/")
+	return endpoint == strings.TrimPrefix(name, "/") || strings.HasSuffix(endpoint, "/"+name)
+}
+
+func (e EndpointRespTypePair) newResp() interface{} {
+	if e.RespDesc != nil {
+		return dynamic.NewMessage(e.RespDesc)
+	}
+	return reflect.New(e.RespType.Elem()).Interface()
 }
 
 type MsgTypeMap map[reflect.Type]EndpointRespTypePair
 
 func NewGRPCClient(i interface{}, target string, opts ...PortOpt) (*ClientPort, error) {
+	d, err := getGrpcDetails(i)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get grpc details")
+	}
+	return newGRPCClient(d, target, opts...)
+}
+
+func newGRPCClient(d *serverDesc, target string, opts ...PortOpt) (*ClientPort, error) {
 	options := defaultPortOpts
 	for _, o := range opts {
 		o(&options)
 	}
 	port := &ClientPort{
 		emd:         make(map[reflect.Type]EndpointRespTypePair),
+		dmd:         make(map[string]EndpointRespTypePair),
 		callResultC: make(chan callResult, 1),
+		streams:     make(map[string]*clientStream),
+		ambiguous:   make(map[string][]string),
 	}
 
-	d, err := getGrpcDetails(i)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to get grpc details")
-	}
 	for _, m := range d.MethodsDesc {
-		port.emd[m.InType] = EndpointRespTypePair{
-			RespType: m.OutType,
-			Endpoint: d.Name + "/" + m.Name,
+		v := EndpointRespTypePair{
+			RespType:     m.OutType,
+			RespDesc:     m.OutDesc,
+			Endpoint:     d.Name + "/" + m.Name,
+			ClientStream: m.ClientStream,
+			ServerStream: m.ServerStream,
 		}
+		if m.InDesc != nil {
+			v.reqKey = m.InDesc.GetFullyQualifiedName()
+			if _, ok := port.dmd[v.reqKey]; !ok {
+				port.dmd[v.reqKey] = v
+			}
+		} else {
+			v.reqKey = m.InType.String()
+			if _, ok := port.emd[m.InType]; !ok {
+				port.emd[m.InType] = v
+			}
+		}
+		port.methods = append(port.methods, v)
+		port.ambiguous[v.reqKey] = append(port.ambiguous[v.reqKey], v.Endpoint)
 	}
-	if err := port.connect(target, options.clientCertPath); err != nil {
+	if err := port.connect(target, options); err != nil {
 		return nil, errors.Wrapf(err, "failed to connect")
 	}
 	return port, nil
@@ -50,37 +100,62 @@ func NewGRPCClientPort(i interface{}, target string, opts ...PortOpt) (*Port, er
 	if err != nil {
 		return nil, err
 	}
-	return &Port{
-		impl: c,
-	}, nil
+	return newPort(c), nil
 }
 
 type connection interface {
 	Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error
+	NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error)
 	Close() error
 }
 
 type ClientPort struct {
 	conn connection
 
-	emd         MsgTypeMap
+	emd MsgTypeMap
+	dmd map[string]EndpointRespTypePair
+	// methods holds endpoints of all service methods, ambiguous holds
+	// endpoint names by request type used by more than one method.
+	methods     []EndpointRespTypePair
+	ambiguous   map[string][]string
 	sendMtx     sync.Mutex
 	callResultC chan callResult
+	// queue holds requeued results received before new ones.
+	queue pendingQueue
+
+	// streams holds open client and bidi streams by endpoint name, stream
+	// is the last used one and the target of GRPCCloseSend message.
+	streams map[string]*clientStream
+	stream  *clientStream
+}
+
+// GRPCCloseSend closes the send direction of the last used client or bidi
+// stream. Messages sent by the server are still delivered by Receive.
+// Server port receives GRPCCloseSend when client of client or bidi stream
+// closed its send direction, Send replies to the stream that delivered it.
+type GRPCCloseSend struct{}
+
+type clientStream struct {
+	endpoint string
+	cs       grpc.ClientStream
 }
 
 type callResult struct {
 	resp interface{}
 	err  error
+
+	header  metadata.MD
+	trailer metadata.MD
 }
 
-func (p *ClientPort) connect(addr, certfile string) error {
+func (p *ClientPort) connect(addr string, opts portOpts) error {
 	options := []grpc.DialOption{grpc.WithInsecure()}
-	if certfile != "" {
-		creds, err := credentials.NewClientTLSFromFile(certfile, strings.Split(addr, ":")[0])
+	if opts.clientCertPath != "" {
+		config, err := opts.clientTLSConfig(strings.Split(addr, ":")[0])
 		if err != nil {
-			return errors.Wrapf(err, "failed to load cert from file %v", certfile)
+			return errors.Wrapf(err, "failed to load cert from file %v", opts.clientCertPath)
 		}
-		options[0] = grpc.WithTransportCredentials(creds)
+		options[0] = grpc.WithTransportCredentials(credentials.NewTLS(config))
 	}
 	var err error
 	c, err := grpc.Dial(addr, options...)
@@ -102,7 +177,7 @@ type res struct {
 }
 
 func (p *ClientPort) Receive(ctx context.Context) (interface{}, error) {
-	return p.receive()
+	return p.receive(PortOpt(withContext(ctx)))
 }
 
 func (p *ClientPort) Send(ctx context.Context, msg interface{}) error {
@@ -115,43 +190,199 @@ func (p *ClientPort) receive(opts ...PortOpt) (interface{}, error) {
 		o(&options)
 	}
 
+	if m, ok := p.queue.pop(); ok {
+		return m.msg, m.err
+	}
 	select {
 	case <-time.Tick(options.timeout):
 		return nil, errors.Errorf("failed to receive  message, deadline exeeded")
+	case <-options.done():
+		return nil, errors.Wrapf(options.ctx.Err(), "failed to receive message")
 	case result := <-p.callResultC:
+		call := &Call{
+			Header:  result.header,
+			Trailer: result.trailer,
+		}
 		if result.err != nil {
-			return nil, result.err
+			return call, result.err
 		}
-		return result.resp, nil
+		call.Msg = result.resp
+		return call, nil
 	}
 }
 
+func (p *ClientPort) requeue(m interface{}, err error) {
+	p.queue.push(m, err)
+}
+
 func (p *ClientPort) send(ctx context.Context, msg interface{}) error {
-	v, ok := p.emd[reflect.TypeOf(msg)]
-	if !ok {
-		return errors.Errorf("port doesn't support message type %T", msg)
+	if _, ok := msg.(*GRPCCloseSend); ok {
+		return p.closeSend()
+	}
+
+	v, err := p.endpoint(msg, getSendMethod(ctx))
+	if err != nil {
+		return err
+	}
+	if v.ClientStream || v.ServerStream {
+		return p.sendStream(ctx, v, msg)
 	}
 	go func() {
-		out := reflect.New(v.RespType.Elem()).Interface()
-		if err := p.conn.Invoke(ctx, v.Endpoint, msg, out); err != nil {
+		out := v.newResp()
+		var header, trailer metadata.MD
+		if err := p.conn.Invoke(ctx, v.Endpoint, msg, out, grpc.Header(&header), grpc.Trailer(&trailer)); err != nil {
 			go func() {
 				p.callResultC <- callResult{
-					err:  err,
-					resp: nil,
+					err:     err,
+					resp:    nil,
+					header:  header,
+					trailer: trailer,
 				}
 			}()
 			return
 		}
-		var resp interface{}
-		rv := reflect.ValueOf(&resp)
-		rv.Elem().Set(reflect.New(v.RespType))
-		rv.Elem().Set(reflect.ValueOf(out))
 		go func() {
 			p.callResultC <- callResult{
-				err:  nil,
-				resp: resp,
+				err:     nil,
+				resp:    out,
+				header:  header,
+				trailer: trailer,
 			}
 		}()
 	}()
 	return nil
 }
+
+// endpoint returns endpoint for generated message by its type and for
+// dynamic message by its proto name. Method has to be selected if the
+// message is request of more than one method.
+func (p *ClientPort) endpoint(msg interface{}, method string) (EndpointRespTypePair, error) {
+	var (
+		v   EndpointRespTypePair
+		ok  bool
+		key string
+	)
+	if dm, isDynamic := msg.(*dynamic.Message); isDynamic {
+		key = dm.GetMessageDescriptor().GetFullyQualifiedName()
+		v, ok = p.dmd[key]
+	} else {
+		key = reflect.TypeOf(msg).String()
+		v, ok = p.emd[reflect.TypeOf(msg)]
+	}
+	if !ok {
+		return v, errors.Errorf("port doesn't support message type %T", msg)
+	}
+
+	if method != "" {
+		for _, m := range p.methods {
+			if m.reqKey == key && m.matchMethod(method) {
+				return m, nil
+			}
+		}
+		return v, errors.Errorf("port has no method %s with request %s", method, key)
+	}
+	if names := p.ambiguous[key]; len(names) > 1 {
+		return v, errors.Errorf("message %s is request of methods %s, select the method with ToMethod send option",
+			key, strings.Join(names, ", "))
+	}
+	return v, nil
+}
+
+func (p *ClientPort) sendStream(ctx context.Context, v EndpointRespTypePair, msg interface{}) error {
+	p.sendMtx.Lock()
+	defer p.sendMtx.Unlock()
+
+	// Server stream is opened by each request, client and bidi streams
+	// are reused by next messages.
+	st, ok := p.streams[v.Endpoint]
+	if !ok || !v.ClientStream {
+		desc := &grpc.StreamDesc{
+			StreamName:    v.Endpoint,
+			ClientStreams: v.ClientStream,
+			ServerStreams: v.ServerStream,
+		}
+		cs, err := p.conn.NewStream(ctx, desc, v.Endpoint)
+		if err != nil {
+			return errors.Wrapf(err, "failed to open %s stream", v.Endpoint)
+		}
+		st = &clientStream{
+			endpoint: v.Endpoint,
+			cs:       cs,
+		}
+		if v.ClientStream {
+			p.streams[v.Endpoint] = st
+		}
+		go p.recvStream(st, v)
+	}
+	if v.ClientStream {
+		p.stream = st
+	}
+
+	if err := st.cs.SendMsg(msg); err != nil {
+		return errors.Wrapf(err, "failed to send message to %s stream", v.Endpoint)
+	}
+	if !v.ClientStream {
+		// Server stream accepts only one request message.
+		return st.cs.CloseSend()
+	}
+	return nil
+}
+
+func (p *ClientPort) closeSend() error {
+	p.sendMtx.Lock()
+	defer p.sendMtx.Unlock()
+
+	if p.stream == nil {
+		return errors.Errorf("no open stream")
+	}
+	return p.stream.cs.CloseSend()
+}
+
+// recvStream delivers stream messages to the port in order they were sent
+// by the server. The stream end is delivered as GRPCStreamEnd message
+// or as an error if the stream was finished with non OK status.
+func (p *ClientPort) recvStream(st *clientStream, v EndpointRespTypePair) {
+	var (
+		err    error
+		header metadata.MD
+	)
+	for {
+		out := v.newResp()
+		if err = st.cs.RecvMsg(out); err != nil {
+			break
+		}
+		if header == nil {
+			header, _ = st.cs.Header()
+		}
+		p.callResultC <- callResult{
+			resp:   out,
+			header: header,
+		}
+	}
+	if header == nil {
+		header, _ = st.cs.Header()
+	}
+
+	p.sendMtx.Lock()
+	if p.streams[st.endpoint] == st {
+		delete(p.streams, st.endpoint)
+	}
+	if p.stream == st {
+		p.stream = nil
+	}
+	p.sendMtx.Unlock()
+
+	if err == io.EOF {
+		p.callResultC <- callResult{
+			resp:    &GRPCStreamEnd{},
+			header:  header,
+			trailer: st.cs.Trailer(),
+		}
+		return
+	}
+	p.callResultC <- callResult{
+		err:     err,
+		header:  header,
+		trailer: st.cs.Trailer(),
+	}
+}
@@ -3,12 +3,16 @@ package port
 import (
 	"context"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/smallinsky/mtf/match"
+	"github.com/smallinsky/mtf/proto/stream"
 )
 
 func TestGrpcClientPort(t *testing.T) {
@@ -85,6 +89,109 @@ func TestGrpcClientPort(t *testing.T) {
 	})
 }
 
+func TestGrpcClientPortStream(t *testing.T) {
+	svr, err := NewGRPCServerPort((*stream.StreamerServer)(nil), ":9993")
+	if err != nil {
+		t.Fatal("failed to create grpc server port: ", err)
+	}
+
+	port, err := NewGRPCClientPort((*stream.StreamerClient)(nil), "localhost:9993")
+	if err != nil {
+		t.Fatal("failed to create grpc client port: ", err)
+	}
+
+	t.Run("AmbiguousMethod", func(t *testing.T) {
+		err := port.impl.Send(context.Background(), &stream.StreamRequest{})
+		if err == nil || !strings.Contains(err.Error(), "ToMethod") {
+			t.Fatalf("expected ambiguous method error, got: %v", err)
+		}
+	})
+
+	t.Run("ServerStream", func(t *testing.T) {
+		port.Send(t, &stream.StreamRequest{
+			Data: "count",
+		}, ToMethod("ServerStream"))
+		svr.Receive(t, &stream.StreamRequest{
+			Data: "count",
+		})
+		for _, v := range []string{"1", "2"} {
+			svr.Send(t, &stream.StreamResponse{
+				Data: v,
+			})
+			port.Receive(t, &stream.StreamResponse{
+				Data: v,
+			})
+		}
+		svr.Send(t, &GRPCStreamEnd{})
+		port.Receive(t, &GRPCStreamEnd{})
+	})
+
+	t.Run("TwoServerStreams", func(t *testing.T) {
+		var calls []*Call
+		for _, v := range []string{"a", "b"} {
+			port.Send(t, &stream.StreamRequest{
+				Data: v,
+			}, ToMethod("ServerStream"))
+			call, _ := svr.Receive(t, &stream.StreamRequest{
+				Data: v,
+			})
+			calls = append(calls, call)
+		}
+		for i, v := range []string{"a", "b"} {
+			calls[i].Send(t, &stream.StreamResponse{
+				Data: v,
+			})
+			port.Receive(t, &stream.StreamResponse{
+				Data: v,
+			})
+		}
+		for _, call := range calls {
+			call.Send(t, &GRPCStreamEnd{})
+			port.Receive(t, &GRPCStreamEnd{})
+		}
+	})
+
+	t.Run("ClientStream", func(t *testing.T) {
+		for _, v := range []string{"1", "2"} {
+			port.Send(t, &stream.StreamRequest{
+				Data: v,
+			}, ToMethod("ClientStream"))
+			svr.Receive(t, &stream.StreamRequest{
+				Data: v,
+			})
+		}
+		port.Send(t, &GRPCCloseSend{})
+		svr.Receive(t, &GRPCCloseSend{})
+		svr.Send(t, &stream.StreamResponse{
+			Data: "3",
+		})
+		svr.Send(t, &GRPCStreamEnd{})
+		port.Receive(t, &stream.StreamResponse{
+			Data: "3",
+		})
+		port.Receive(t, &GRPCStreamEnd{})
+	})
+
+	t.Run("BidiStreamStatus", func(t *testing.T) {
+		port.Send(t, &stream.StreamRequest{
+			Data: "ping",
+		}, ToMethod("/stream.Streamer/BidiStream"))
+		svr.Receive(t, &stream.StreamRequest{
+			Data: "ping",
+		})
+		svr.Send(t, &stream.StreamResponse{
+			Data: "pong",
+		})
+		port.Receive(t, &stream.StreamResponse{
+			Data: "pong",
+		})
+		svr.Send(t, &GRPCStreamEnd{
+			Err: status.Error(codes.Aborted, "stream aborted"),
+		})
+		port.Receive(t, match.GRPCStatusCode(codes.Aborted))
+	})
+}
+
 type mockConnection struct {
 	t   *testing.T
 	err error
@@ -102,6 +209,10 @@ func (m *mockConnection) Invoke(ctx context.Context, method string, args, reply
 
 	return m.err
 }
+func (m *mockConnection) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return nil, m.err
+}
+
 func (m *mockConnection) Close() error {
 	return nil
 }
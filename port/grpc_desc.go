@@ -10,6 +10,8 @@ import (
 
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
 	"github.com/pkg/errors"
 )
 
@@ -22,6 +24,51 @@ type methodDesc struct {
 	InType  reflect.Type
 	OutType reflect.Type
 	Name    string
+
+	ClientStream bool
+	ServerStream bool
+
+	// InDesc and OutDesc are set for methods loaded from proto descriptors,
+	// their messages are handled as dynamic messages.
+	InDesc  *desc.MessageDescriptor
+	OutDesc *desc.MessageDescriptor
+}
+
+func (m methodDesc) isStream() bool {
+	return m.ClientStream || m.ServerStream
+}
+
+func (m methodDesc) newIn() interface{} {
+	if m.InDesc != nil {
+		return dynamic.NewMessage(m.InDesc)
+	}
+	return reflect.New(m.InType.Elem()).Interface()
+}
+
+func (m methodDesc) isOut(msg proto.Message) bool {
+	if m.OutDesc != nil {
+		return messageName(msg) == m.OutDesc.GetFullyQualifiedName()
+	}
+	return reflect.TypeOf(msg) == m.OutType
+}
+
+// sameMessageType reports whether messages are of the same go type, dynamic
+// messages are compared by proto name.
+func sameMessageType(a, b interface{}) bool {
+	am, aok := a.(*dynamic.Message)
+	bm, bok := b.(*dynamic.Message)
+	if aok && bok {
+		return messageName(am) == messageName(bm)
+	}
+	return reflect.TypeOf(a) == reflect.TypeOf(b)
+}
+
+// messageName returns full proto name of generated or dynamic message.
+func messageName(msg proto.Message) string {
+	if dm, ok := msg.(*dynamic.Message); ok {
+		return dm.GetMessageDescriptor().GetFullyQualifiedName()
+	}
+	return proto.MessageName(msg)
 }
 
 func getGrpcDetails(s interface{}) (*serverDesc, error) {
@@ -43,17 +90,86 @@ func getGrpcDetails(s interface{}) (*serverDesc, error) {
 	desc.Name = sn + "." + name
 
 	for i := 0; i < t.NumMethod(); i++ {
-		// TODO: distinguish stream methods
-		m := t.Method(i)
-		desc.MethodsDesc = append(desc.MethodsDesc, methodDesc{
-			Name:    m.Name,
-			InType:  m.Type.In(1),
-			OutType: m.Type.Out(0),
-		})
+		md, err := getMethodDesc(t.Method(i))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get %s method details", t.Method(i).Name)
+		}
+		desc.MethodsDesc = append(desc.MethodsDesc, md)
 	}
 	return &desc, nil
 }
 
+// getMethodDesc returns method details based on generated grpc server or
+// client interface method. Stream methods are recognized by stream interface
+// argument and their message types are taken from Send/Recv stream methods.
+func getMethodDesc(m reflect.Method) (methodDesc, error) {
+	md := methodDesc{
+		Name: m.Name,
+	}
+
+	var st reflect.Type
+	for i := 0; i < m.Type.NumIn(); i++ {
+		switch in := m.Type.In(i); {
+		case isProtoMessage(in):
+			md.InType = in
+		case isGrpcStream(in):
+			st = in
+		}
+	}
+	for i := 0; i < m.Type.NumOut(); i++ {
+		switch out := m.Type.Out(i); {
+		case isProtoMessage(out):
+			md.OutType = out
+		case isGrpcStream(out):
+			st = out
+		}
+	}
+
+	if st != nil {
+		_, clientSide := st.MethodByName("CloseSend")
+		for i := 0; i < st.NumMethod(); i++ {
+			sm := st.Method(i)
+			switch {
+			case sm.Name == "Send" && clientSide:
+				md.ClientStream = true
+				md.InType = sm.Type.In(0)
+			case sm.Name == "Send":
+				md.ServerStream = true
+				md.OutType = sm.Type.In(0)
+			case sm.Name == "Recv" && clientSide:
+				md.ServerStream = true
+				md.OutType = sm.Type.Out(0)
+			case sm.Name == "Recv":
+				md.ClientStream = true
+				md.InType = sm.Type.Out(0)
+			case sm.Name == "SendAndClose":
+				md.OutType = sm.Type.In(0)
+			case sm.Name == "CloseAndRecv":
+				md.OutType = sm.Type.Out(0)
+			}
+		}
+	}
+
+	if md.InType == nil || md.OutType == nil {
+		return md, errors.Errorf("unsupported method prototype %v", m.Type)
+	}
+	return md, nil
+}
+
+func isProtoMessage(t reflect.Type) bool {
+	return t.Kind() == reflect.Ptr && t.Implements(protoMessageType)
+}
+
+func isGrpcStream(t reflect.Type) bool {
+	if t.Kind() != reflect.Interface {
+		return false
+	}
+	_, ok := t.MethodByName("SendMsg")
+	return ok
+}
+
+var protoMessageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+
 func getProtoDescFromBuff(buff []byte) (*descriptor.FileDescriptorProto, error) {
 	r, err := gzip.NewReader(bytes.NewReader(buff))
 	if err != nil {
@@ -79,8 +195,11 @@ func getPackageName(s interface{}) (string, error) {
 		t = t.Elem()
 	}
 	for i := 0; i < t.NumMethod(); i++ {
-		m := t.Method(i)
-		n1 := reflect.Zero(m.Type.In(1))
+		md, err := getMethodDesc(t.Method(i))
+		if err != nil {
+			continue
+		}
+		n1 := reflect.Zero(md.InType)
 		mm := n1.MethodByName("Descriptor")
 		if !mm.IsValid() {
 			continue
@@ -0,0 +1,170 @@
+package port
+
+import (
+	"io/ioutil"
+	"reflect"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/pkg/errors"
+)
+
+// ProtoDesc holds proto descriptors used by dynamic grpc ports. Dynamic ports
+// don't need generated go code, messages are handled as *dynamic.Message
+// that can be created from JSON or text format by MessageFromJSON and
+// MessageFromText functions.
+type ProtoDesc struct {
+	files map[string]*desc.FileDescriptor
+}
+
+// NewProtoDesc creates proto descriptors from FileDescriptorSet. The set has
+// to contain all imported files, protoc --include_imports flag can be used
+// to generate such set.
+func NewProtoDesc(fds *descriptor.FileDescriptorSet) (*ProtoDesc, error) {
+	files, err := desc.CreateFileDescriptorsFromSet(fds)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create file descriptors")
+	}
+	return &ProtoDesc{
+		files: files,
+	}, nil
+}
+
+// LoadProtoDesc loads proto descriptors from binary FileDescriptorSet file
+// generated by protoc --descriptor_set_out flag.
+func LoadProtoDesc(path string) (*ProtoDesc, error) {
+	buff, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", path)
+	}
+	fds := &descriptor.FileDescriptorSet{}
+	if err := proto.Unmarshal(buff, fds); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal %s", path)
+	}
+	return NewProtoDesc(fds)
+}
+
+// ParseProtoFiles creates proto descriptors from .proto source files.
+// Files and their imports are looked up in importPaths.
+func ParseProtoFiles(importPaths []string, files ...string) (*ProtoDesc, error) {
+	parser := protoparse.Parser{
+		ImportPaths: importPaths,
+	}
+	fds, err := parser.ParseFiles(files...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse proto files")
+	}
+	pd := &ProtoDesc{
+		files: make(map[string]*desc.FileDescriptor),
+	}
+	for _, fd := range fds {
+		pd.files[fd.GetName()] = fd
+	}
+	return pd, nil
+}
+
+// MessageFromJSON creates dynamic message of name type from JSON data.
+func (d *ProtoDesc) MessageFromJSON(name string, data string) (*dynamic.Message, error) {
+	md, err := d.findMessage(name)
+	if err != nil {
+		return nil, err
+	}
+	msg := dynamic.NewMessage(md)
+	if err := msg.UnmarshalJSON([]byte(data)); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal %s from json", name)
+	}
+	return msg, nil
+}
+
+// MessageFromText creates dynamic message of name type from proto text format data.
+func (d *ProtoDesc) MessageFromText(name string, data string) (*dynamic.Message, error) {
+	md, err := d.findMessage(name)
+	if err != nil {
+		return nil, err
+	}
+	msg := dynamic.NewMessage(md)
+	if err := msg.UnmarshalText([]byte(data)); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal %s from text", name)
+	}
+	return msg, nil
+}
+
+func (d *ProtoDesc) findMessage(name string) (*desc.MessageDescriptor, error) {
+	for _, fd := range d.files {
+		if md := fd.FindMessage(name); md != nil {
+			return md, nil
+		}
+	}
+	return nil, errors.Errorf("message %s not found", name)
+}
+
+func (d *ProtoDesc) findService(name string) (*desc.ServiceDescriptor, error) {
+	for _, fd := range d.files {
+		if sd := fd.FindService(name); sd != nil {
+			return sd, nil
+		}
+	}
+	return nil, errors.Errorf("service %s not found", name)
+}
+
+func (d *ProtoDesc) getGrpcDetails(service string) (*serverDesc, error) {
+	sd, err := d.findService(service)
+	if err != nil {
+		return nil, err
+	}
+	dt := reflect.TypeOf((*dynamic.Message)(nil))
+	details := serverDesc{
+		Name: sd.GetFullyQualifiedName(),
+	}
+	for _, m := range sd.GetMethods() {
+		details.MethodsDesc = append(details.MethodsDesc, methodDesc{
+			Name:         m.GetName(),
+			InType:       dt,
+			OutType:      dt,
+			InDesc:       m.GetInputType(),
+			OutDesc:      m.GetOutputType(),
+			ClientStream: m.IsClientStreaming(),
+			ServerStream: m.IsServerStreaming(),
+		})
+	}
+	return &details, nil
+}
+
+// NewDynamicGRPCServerPort creates grpc server port for service with full
+// proto name, like "oracle.Oracle", defined in proto descriptors.
+func NewDynamicGRPCServerPort(pd *ProtoDesc, service, port string, opts ...PortOpt) (*Port, error) {
+	p, err := NewDynamicGRPCServer(pd, service, port, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return newPort(p), nil
+}
+
+func NewDynamicGRPCServer(pd *ProtoDesc, service, port string, opts ...PortOpt) (*PortIn, error) {
+	d, err := pd.getGrpcDetails(service)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get grpc details")
+	}
+	return newGRPCServer([]*serverDesc{d}, port, opts...)
+}
+
+// NewDynamicGRPCClientPort creates grpc client port for service with full
+// proto name, like "echo.Echo", defined in proto descriptors.
+func NewDynamicGRPCClientPort(pd *ProtoDesc, service, target string, opts ...PortOpt) (*Port, error) {
+	c, err := NewDynamicGRPCClient(pd, service, target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return newPort(c), nil
+}
+
+func NewDynamicGRPCClient(pd *ProtoDesc, service, target string, opts ...PortOpt) (*ClientPort, error) {
+	d, err := pd.getGrpcDetails(service)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get grpc details")
+	}
+	return newGRPCClient(d, target, opts...)
+}
@@ -0,0 +1,83 @@
+package port
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	"github.com/smallinsky/mtf/proto/oracle"
+)
+
+func TestDynamicGRPCServer(t *testing.T) {
+	pd, err := ParseProtoFiles([]string{"../proto/oracle"}, "oracle.proto")
+	if err != nil {
+		t.Fatal("failed to parse proto files: ", err)
+	}
+	svr, err := NewDynamicGRPCServerPort(pd, "oracle.Oracle", ":9995")
+	if err != nil {
+		t.Fatal("failed to create dynamic grpc server port: ", err)
+	}
+	conn, err := grpc.Dial("localhost:9995", grpc.WithInsecure())
+	if err != nil {
+		t.Fatal("fialed to dial oracle address: ", err)
+	}
+	defer conn.Close()
+
+	req, err := pd.MessageFromJSON("oracle.AskDeepThoughtRequest", `{"data": "Ultimate question"}`)
+	if err != nil {
+		t.Fatal("failed to create request: ", err)
+	}
+	resp, err := pd.MessageFromText("oracle.AskDeepThoughtResponse", `data: "42"`)
+	if err != nil {
+		t.Fatal("failed to create response: ", err)
+	}
+
+	go func() {
+		svr.Receive(t, req)
+		svr.Send(t, resp)
+	}()
+
+	got, err := oracle.NewOracleClient(conn).AskDeepThought(context.Background(), &oracle.AskDeepThoughtRequest{
+		Data: "Ultimate question",
+	})
+	if err != nil {
+		t.Fatal("faield to ask deep through: ", err)
+	}
+	if got, exp := got.GetData(), "42"; got != exp {
+		t.Fatalf("Got: '%v' Expected: '%v'", got, exp)
+	}
+}
+
+func TestDynamicGRPCClient(t *testing.T) {
+	pd, err := ParseProtoFiles([]string{"../proto/oracle"}, "oracle.proto")
+	if err != nil {
+		t.Fatal("failed to parse proto files: ", err)
+	}
+	svr, err := NewGRPCServerPort((*oracle.OracleServer)(nil), ":9996")
+	if err != nil {
+		t.Fatal("failed to create grpc server port: ", err)
+	}
+	port, err := NewDynamicGRPCClientPort(pd, "oracle.Oracle", "localhost:9996")
+	if err != nil {
+		t.Fatal("failed to create dynamic grpc client port: ", err)
+	}
+
+	req, err := pd.MessageFromText("oracle.AskDeepThoughtRequest", `data: "Ultimate question"`)
+	if err != nil {
+		t.Fatal("failed to create request: ", err)
+	}
+	resp, err := pd.MessageFromJSON("oracle.AskDeepThoughtResponse", `{"data": "42"}`)
+	if err != nil {
+		t.Fatal("failed to create response: ", err)
+	}
+
+	port.Send(t, req)
+	svr.Receive(t, &oracle.AskDeepThoughtRequest{
+		Data: "Ultimate question",
+	})
+	svr.Send(t, &oracle.AskDeepThoughtResponse{
+		Data: "42",
+	})
+	port.Receive(t, resp)
+}
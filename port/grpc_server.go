@@ -2,41 +2,105 @@ package port
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
+	"io"
 	"log"
 	"reflect"
 	"strings"
 	"sync"
 	"time"
-	"unsafe"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 
 	"github.com/smallinsky/mtf/pkg/netw"
 )
 
-type processFunc func(i interface{}) (interface{}, error)
+type processFunc func(ctx context.Context, method string, i interface{}) (interface{}, error)
+
+type streamFunc func(method string, desc methodDesc, stream grpc.ServerStream) error
 
 type outValues struct {
 	msg interface{}
 	err error
+
+	values sendValues
+}
+
+type inValues struct {
+	msg  interface{}
+	call serverCall
+	// method is full grpc method name in "/package.Service/Method" format.
+	method   string
+	md       metadata.MD
+	deadline time.Time
+	peerCert *x509.Certificate
+}
+
+// inFilter selects received messages, not selected messages stay queued
+// in the port until they are received.
+type inFilter func(v inValues) bool
+
+func receiveFilter(opts receiveOptions) inFilter {
+	return func(v inValues) bool {
+		if opts.method != "" && v.method != opts.method && !strings.HasSuffix(v.method, "/"+opts.method) {
+			return false
+		}
+		if opts.msgType != nil && !sameMessageType(v.msg, opts.msgType) {
+			return false
+		}
+		return true
+	}
+}
+
+// serverCall is unary call or stream that delivered received message.
+type serverCall interface {
+	send(ctx context.Context, msg interface{}) error
 }
 
 type PortIn struct {
-	reqC  chan interface{}
-	respC chan outValues
+	reqC chan inValues
+
+	// pending holds messages skipped by filtered receive calls, notifyC is
+	// closed when new message is queued.
+	pending []inValues
+	notifyC chan struct{}
+
+	// call is the call that delivered last received message, it is
+	// the target of the port send calls.
+	call serverCall
+	mtx  sync.Mutex
+
+	stubs *stubSet
 }
 
+// GRPCStreamEnd closes the stream that delivered last received message.
+// The stream is finished with Err status or with OK code if Err is nil.
+// Client port receives GRPCStreamEnd when server finished the stream with OK code.
+type GRPCStreamEnd struct {
+	Err error
+}
+
+// Send replies to the call that delivered last received message. Response
+// header and trailer can be set by WithHeader and WithTrailer send options.
 func (p *PortIn) Send(ctx context.Context, i interface{}) error {
-	return p.send(i)
+	return p.send(ctx, i)
 }
 
+// Receive returns *Call with received message, the call Send method
+// replies to the grpc call that delivered the message.
 func (p *PortIn) Receive(ctx context.Context) (interface{}, error) {
-	return p.receive()
+	return p.receive(receiveFilter(receiveOptions{}), withContext(ctx))
+}
+
+func (p *PortIn) receiveSelected(ctx context.Context, opts receiveOptions) (interface{}, error) {
+	return p.receive(receiveFilter(opts), withContext(ctx))
 }
 
 func NewGRPCServersPort(ii []interface{}, port string, opts ...PortOpt) (*Port, error) {
@@ -44,9 +108,7 @@ func NewGRPCServersPort(ii []interface{}, port string, opts ...PortOpt) (*Port,
 	if err != nil {
 		return nil, err
 	}
-	return &Port{
-		impl: p,
-	}, nil
+	return newPort(p), nil
 }
 
 func NewGRPCServerPort(i interface{}, port string, opts ...PortOpt) (*Port, error) {
@@ -54,59 +116,35 @@ func NewGRPCServerPort(i interface{}, port string, opts ...PortOpt) (*Port, erro
 	if err != nil {
 		return nil, err
 	}
-	return &Port{
-		impl: p,
-	}, nil
+	return newPort(p), nil
 }
 
 func NewGRPCServers(ii []interface{}, port string, opts ...PortOpt) (*PortIn, error) {
-	options := defaultPortOpts
-	for _, o := range opts {
-		o(&options)
-	}
-
-	portIn := &PortIn{
-		reqC:  make(chan interface{}),
-		respC: make(chan outValues),
-	}
-
-	lis, err := netw.Listen("tcp", port)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to create net listener")
-	}
-
-	var grpcOpts []grpc.ServerOption
-	if options.serverCertPath != "" && options.serverKeyPath != "" {
-		creds, err := credentials.NewServerTLSFromFile(options.serverCertPath, options.serverKeyPath)
+	var descs []*serverDesc
+	for _, i := range ii {
+		desc, err := getGrpcDetails(i)
 		if err != nil {
-			return nil, errors.Wrapf(err, "failed to load TLS certs")
+			return nil, errors.Wrapf(err, "failed ot get grpc details")
 		}
-		grpcOpts = append(grpcOpts, grpc.Creds(creds))
-	}
-
-	s, err := registerInterfaces(grpc.NewServer(grpcOpts...), ii, portIn.rpcCallHandler, options)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to reqigster server interface")
+		descs = append(descs, desc)
 	}
-
-	go func() {
-		if err := s.Serve(lis); err != nil {
-			log.Fatalf("Failed to server %v", err)
-		}
-	}()
-
-	return portIn, nil
+	return newGRPCServer(descs, port, opts...)
 }
 
 func NewGRPCServer(i interface{}, port string, opts ...PortOpt) (*PortIn, error) {
+	return NewGRPCServers([]interface{}{i}, port, opts...)
+}
+
+func newGRPCServer(descs []*serverDesc, port string, opts ...PortOpt) (*PortIn, error) {
 	options := defaultPortOpts
 	for _, o := range opts {
 		o(&options)
 	}
 
 	portIn := &PortIn{
-		reqC:  make(chan interface{}),
-		respC: make(chan outValues),
+		reqC:    make(chan inValues),
+		notifyC: make(chan struct{}),
+		stubs:   newStubSet(),
 	}
 
 	lis, err := netw.Listen("tcp", port)
@@ -116,16 +154,18 @@ func NewGRPCServer(i interface{}, port string, opts ...PortOpt) (*PortIn, error)
 
 	var grpcOpts []grpc.ServerOption
 	if options.serverCertPath != "" && options.serverKeyPath != "" {
-		creds, err := credentials.NewServerTLSFromFile(options.serverCertPath, options.serverKeyPath)
+		config, err := options.serverTLSConfig()
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to load TLS certs")
 		}
-		grpcOpts = append(grpcOpts, grpc.Creds(creds))
+		grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(config)))
 	}
 
-	s, err := registerInterface(grpc.NewServer(grpcOpts...), i, portIn.rpcCallHandler, options)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to register server interface")
+	s := grpc.NewServer(grpcOpts...)
+	for _, desc := range descs {
+		// Handlers don't use service implementation so any value can be
+		// passed as the service, HandlerType check is satisfied by empty interface.
+		s.RegisterService(newServiceDesc(desc, portIn.rpcCallHandler, portIn.rpcStreamHandler), struct{}{})
 	}
 
 	var wg sync.WaitGroup
@@ -140,147 +180,369 @@ func NewGRPCServer(i interface{}, port string, opts ...PortOpt) (*PortIn, error)
 	return portIn, nil
 }
 
-func (p *PortIn) rpcCallHandler(req interface{}) (interface{}, error) {
-	go func() {
-		p.reqC <- req
-	}()
-	resp := <-p.respC
+func (p *PortIn) rpcCallHandler(ctx context.Context, method string, req interface{}) (interface{}, error) {
+	call := &unaryCall{
+		respC: make(chan outValues, 1),
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	deadline, _ := ctx.Deadline()
+	in := inValues{msg: req, call: call, method: method, md: md, deadline: deadline, peerCert: peerCert(ctx)}
+	if stub, ok := p.stubs.reply(req); ok {
+		if err := call.send(ctx, stub); err != nil {
+			return nil, err
+		}
+	} else {
+		go func() {
+			p.reqC <- in
+		}()
+	}
+	resp := <-call.respC
+	if err := resp.values.wait(ctx); err != nil {
+		return nil, err
+	}
+	if resp.values.header != nil {
+		if err := grpc.SetHeader(ctx, resp.values.header); err != nil {
+			return nil, err
+		}
+	}
+	if resp.values.trailer != nil {
+		if err := grpc.SetTrailer(ctx, resp.values.trailer); err != nil {
+			return nil, err
+		}
+	}
 	return resp.msg, resp.err
 }
 
-func (p *PortIn) receive(opts ...Opt) (interface{}, error) {
-	options := defaultPortOpts
-	for _, o := range opts {
-		o(&options)
+func (p *PortIn) stubSet() *stubSet {
+	return p.stubs
+}
+
+// peerCert returns verified client certificate of mTLS call.
+func peerCert(ctx context.Context) *x509.Certificate {
+	pr, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	info, ok := pr.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.PeerCertificates) == 0 {
+		return nil
+	}
+	return info.State.PeerCertificates[0]
+}
+
+type unaryCall struct {
+	respC chan outValues
+}
+
+func (c *unaryCall) send(ctx context.Context, msg interface{}) error {
+	out := outValues{
+		values: getSendValues(ctx),
+	}
+	switch t := msg.(type) {
+	case *GRPCErr:
+		err, serr := t.status()
+		if serr != nil {
+			return serr
+		}
+		out.err = err
+	case proto.Message:
+		out.msg = t
+	default:
+		return fmt.Errorf("invalid message type %T", msg)
 	}
 
 	select {
-	case <-time.NewTimer(options.timeout).C:
-		return nil, errors.Errorf("failed to receive  message, deadline exceeded")
-	case v := <-p.reqC:
-		return v, nil
+	case c.respC <- out:
+		return nil
+	default:
+		return fmt.Errorf("response for the call already sent")
 	}
 }
 
-type GRPCErr struct {
-	Err error
+type serverStream struct {
+	desc methodDesc
+	ss   grpc.ServerStream
+	outC chan streamOut
 }
 
-func (p *PortIn) send(msg interface{}, opts ...PortOpt) error {
-	options := defaultPortOpts
-	for _, o := range opts {
-		o(&options)
+type streamOut struct {
+	msg  interface{}
+	errC chan error
+
+	values sendValues
+}
+
+func (p *PortIn) rpcStreamHandler(method string, desc methodDesc, ss grpc.ServerStream) error {
+	st := &serverStream{
+		desc: desc,
+		ss:   ss,
+		outC: make(chan streamOut),
+	}
+	ctx := ss.Context()
+	md, _ := metadata.FromIncomingContext(ctx)
+	deadline, _ := ctx.Deadline()
+	cert := peerCert(ctx)
+
+	go func() {
+		for {
+			msg := desc.newIn()
+			if err := ss.RecvMsg(msg); err != nil {
+				if err == io.EOF && desc.ClientStream {
+					select {
+					case p.reqC <- inValues{msg: &GRPCCloseSend{}, call: st, method: method, md: md, deadline: deadline, peerCert: cert}:
+					case <-ctx.Done():
+					}
+				}
+				return
+			}
+			if stub, ok := p.stubs.reply(msg); ok {
+				if err := st.send(ctx, stub); err != nil {
+					return
+				}
+				continue
+			}
+			select {
+			case p.reqC <- inValues{msg: msg, call: st, method: method, md: md, deadline: deadline, peerCert: cert}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case out := <-st.outC:
+			if err := out.values.wait(ctx); err != nil {
+				out.errC <- err
+				return err
+			}
+			if out.values.header != nil {
+				if err := ss.SetHeader(out.values.header); err != nil {
+					out.errC <- err
+					continue
+				}
+			}
+			if out.values.trailer != nil {
+				ss.SetTrailer(out.values.trailer)
+			}
+			switch t := out.msg.(type) {
+			case *GRPCStreamEnd:
+				out.errC <- nil
+				return t.Err
+			case *GRPCErr:
+				out.errC <- nil
+				return t.Err
+			default:
+				out.errC <- ss.SendMsg(t)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
+}
 
+func (s *serverStream) send(ctx context.Context, msg interface{}) error {
 	switch t := msg.(type) {
-	case *GRPCErr:
+	case *GRPCStreamEnd:
 		if _, ok := status.FromError(t.Err); !ok {
 			return fmt.Errorf("invalid error type")
 		}
-		p.respC <- outValues{
-			msg: nil,
-			err: t.Err,
+	case *GRPCErr:
+		err, serr := t.status()
+		if serr != nil {
+			return serr
+		}
+		msg = &GRPCErr{
+			Err: err,
 		}
 	case proto.Message:
-		p.respC <- outValues{
-			msg: t,
-			err: options.err,
+		if !s.desc.isOut(t) {
+			return fmt.Errorf("invalid stream %s message type %v", s.desc.Name, messageName(t))
 		}
 	default:
 		return fmt.Errorf("invalid message type %T", msg)
 	}
 
-	return nil
+	errC := make(chan error, 1)
+	out := streamOut{
+		msg:    msg,
+		errC:   errC,
+		values: getSendValues(ctx),
+	}
+	select {
+	case s.outC <- out:
+	case <-s.ss.Context().Done():
+		return errors.Wrapf(s.ss.Context().Err(), "stream %s closed", s.desc.Name)
+	}
+	return <-errC
 }
 
-func registerInterface(server *grpc.Server, i interface{}, procCall processFunc, opts portOpts) (*grpc.Server, error) {
-	sv := reflect.ValueOf(&server).Elem().Elem().FieldByName("m")
-	nsv := reflect.New(sv.Type().Elem().Elem())
-	mdv := nsv.Elem().FieldByName("md")
-	//TODO register handler for stream methods
-
-	mv := allocMap(mdv)
-
-	desc, err := getGrpcDetails(i)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed ot get grpc details")
+func (p *PortIn) receive(filter inFilter, opts ...Opt) (interface{}, error) {
+	options := defaultPortOpts
+	for _, o := range opts {
+		o(&options)
 	}
-	for _, mdesc := range desc.MethodsDesc {
-		mdesc := mdesc
-		fn := func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-			v := reflect.New(mdesc.InType)
-			v.Elem().Set(reflect.New(mdesc.InType.Elem()))
-			dec(v.Elem().Interface())
-			return procCall(v.Elem().Interface())
-		}
 
-		z := reflect.New(mdv.Type().Elem().Elem())
-		z.Elem().FieldByName("MethodName").SetString(mdesc.Name)
-		z.Elem().FieldByName("Handler").Set(reflect.ValueOf(fn))
+	timer := time.NewTimer(options.timeout)
+	defer timer.Stop()
+	for {
+		p.mtx.Lock()
+		v, ok := p.takePending(filter)
+		notifyC := p.notifyC
+		p.mtx.Unlock()
+		if ok {
+			return p.accept(v), nil
+		}
 
-		serverName := ""
-		if opts.pkgName != "" {
-			serverName = strings.Join([]string{opts.pkgName, mdesc.Name}, ".")
-		} else {
-			serverName = mdesc.Name
+		select {
+		case <-timer.C:
+			return nil, errors.Errorf("failed to receive  message, deadline exceeded")
+		case <-options.done():
+			return nil, errors.Wrapf(options.ctx.Err(), "failed to receive message")
+		case v := <-p.reqC:
+			if filter(v) {
+				return p.accept(v), nil
+			}
+			p.mtx.Lock()
+			p.pending = append(p.pending, v)
+			close(p.notifyC)
+			p.notifyC = make(chan struct{})
+			p.mtx.Unlock()
+		case <-notifyC:
 		}
-		mv.Elem().SetMapIndex(reflect.ValueOf(serverName), z)
 	}
+}
 
-	mv = allocMap(sv)
-	mv.Elem().SetMapIndex(reflect.ValueOf(desc.Name), nsv)
-	return server, nil
+func (p *PortIn) takePending(filter inFilter) (inValues, bool) {
+	for i, v := range p.pending {
+		if filter(v) {
+			p.pending = append(p.pending[:i], p.pending[i+1:]...)
+			return v, true
+		}
+	}
+	return inValues{}, false
 }
 
-func registerInterfaces(server *grpc.Server, ii []interface{}, procCall processFunc, opts portOpts) (*grpc.Server, error) {
-	sv := reflect.ValueOf(&server).Elem().Elem().FieldByName("m")
+func (p *PortIn) accept(v inValues) *Call {
+	p.mtx.Lock()
+	prev := p.call
+	p.call = v.call
+	p.mtx.Unlock()
+	return &Call{
+		Msg:      v.msg,
+		Metadata: v.md,
+		Deadline: v.deadline,
+		PeerCert: v.peerCert,
+		send:     v.call.send,
+		value: acceptedIn{
+			v:    v,
+			prev: prev,
+		},
+	}
+}
 
-	svm := allocMap(sv)
+// acceptedIn is received message with send target it replaced.
+type acceptedIn struct {
+	v    inValues
+	prev serverCall
+}
 
-	for _, i := range ii {
-		nsv := reflect.New(sv.Type().Elem().Elem())
-		mdv := nsv.Elem().FieldByName("md")
-		mv := allocMap(mdv)
-		//TODO register handler for stream methods
+// requeue puts received call back in front of pending messages and
+// restores the previous send target.
+func (p *PortIn) requeue(m interface{}, err error) {
+	call, _ := m.(*Call)
+	if call == nil {
+		return
+	}
+	a, ok := call.value.(acceptedIn)
+	if !ok {
+		return
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.pending = append([]inValues{a.v}, p.pending...)
+	if p.call == a.v.call {
+		p.call = a.prev
+	}
+	close(p.notifyC)
+	p.notifyC = make(chan struct{})
+}
 
-		desc, err := getGrpcDetails(i)
-		if err != nil {
-			return nil, errors.Wrapf(err, "failed ot get grpc details")
-		}
-		for _, mdesc := range desc.MethodsDesc {
-			mdesc := mdesc
-			fn := func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-				v := reflect.New(mdesc.InType)
-				v.Elem().Set(reflect.New(mdesc.InType.Elem()))
-				if err := dec(v.Elem().Interface()); err != nil {
-					return nil, err
-				}
-				return procCall(v.Elem().Interface())
-			}
+type GRPCErr struct {
+	Err error
+	// Details are attached to Err status as google.rpc.Status details,
+	// like errdetails.RetryInfo or errdetails.BadRequest messages.
+	Details []proto.Message
+}
 
-			z := reflect.New(mdv.Type().Elem().Elem())
-			z.Elem().FieldByName("MethodName").SetString(mdesc.Name)
-			z.Elem().FieldByName("Handler").Set(reflect.ValueOf(fn))
+// status returns Err with Details attached to its status.
+func (e *GRPCErr) status() (error, error) {
+	st, ok := status.FromError(e.Err)
+	if !ok {
+		return nil, fmt.Errorf("invalid error type")
+	}
+	if len(e.Details) == 0 {
+		return e.Err, nil
+	}
+	st, err := st.WithDetails(e.Details...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to attach error details")
+	}
+	return st.Err(), nil
+}
 
-			serverName := ""
-			if opts.pkgName != "" {
-				serverName = strings.Join([]string{opts.pkgName, mdesc.Name}, ".")
-			} else {
-				serverName = mdesc.Name
-			}
-			mv.Elem().SetMapIndex(reflect.ValueOf(serverName), z)
-		}
+func (p *PortIn) send(ctx context.Context, msg interface{}) error {
+	p.mtx.Lock()
+	call := p.call
+	p.mtx.Unlock()
 
-		svm.Elem().SetMapIndex(reflect.ValueOf(desc.Name), nsv)
+	if call == nil {
+		return fmt.Errorf("no call received")
 	}
-
-	return server, nil
+	return call.send(ctx, msg)
 }
 
-func allocMap(v reflect.Value) reflect.Value {
-	vm := reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr()))
-	vm.Elem().Set(reflect.MakeMap(vm.Elem().Type()))
-	return vm
+func newServiceDesc(desc *serverDesc, procCall processFunc, procStream streamFunc) *grpc.ServiceDesc {
+	sd := &grpc.ServiceDesc{
+		ServiceName: desc.Name,
+		HandlerType: (*interface{})(nil),
+	}
+	for _, mdesc := range desc.MethodsDesc {
+		mdesc := mdesc
+		method := "/" + desc.Name + "/" + mdesc.Name
+		if mdesc.isStream() {
+			sd.Streams = append(sd.Streams, grpc.StreamDesc{
+				StreamName: mdesc.Name,
+				Handler: func(srv interface{}, stream grpc.ServerStream) error {
+					return procStream(method, mdesc, stream)
+				},
+				ServerStreams: mdesc.ServerStream,
+				ClientStreams: mdesc.ClientStream,
+			})
+			continue
+		}
+		sd.Methods = append(sd.Methods, grpc.MethodDesc{
+			MethodName: mdesc.Name,
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := mdesc.newIn()
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return procCall(ctx, method, in)
+				}
+				info := &grpc.UnaryServerInfo{
+					Server:     srv,
+					FullMethod: method,
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return procCall(ctx, method, req)
+				}
+				return interceptor(ctx, in, info, handler)
+			},
+		})
+	}
+	return sd
 }
 
 func getServerDesc(s interface{}) (name string, methods []string) {
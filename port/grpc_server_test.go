@@ -3,13 +3,23 @@ package port
 import (
 	"context"
 	"fmt"
+	"io"
+	"reflect"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	"github.com/smallinsky/mtf/match"
 	"github.com/smallinsky/mtf/proto/oracle"
+	"github.com/smallinsky/mtf/proto/stream"
 )
 
 func TestGRPCServer(t *testing.T) {
@@ -106,6 +116,50 @@ func TestGRPCServer(t *testing.T) {
 			t.Fatalf("Got: '%v' Expected: '%v'", got, exp)
 		}
 	})
+
+	t.Run("ConcurrentCallsReplyInReverseOrder", func(t *testing.T) {
+		const (
+			N = 10
+		)
+
+		go func() {
+			var calls []*Call
+			for i := 0; i < N; i++ {
+				call, _ := svr.Receive(t, match.Type(&oracle.AskDeepThoughtRequest{}))
+				calls = append(calls, call)
+			}
+			for i := len(calls) - 1; i >= 0; i-- {
+				calls[i].Send(t, &oracle.AskDeepThoughtResponse{
+					Data: calls[i].Msg.(*oracle.AskDeepThoughtRequest).GetData(),
+				})
+			}
+		}()
+
+		var wg sync.WaitGroup
+		errC := make(chan error, N)
+		for i := 0; i < N; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				req := fmt.Sprintf("Request: %v", i)
+				resp, err := client.AskDeepThought(context.Background(), &oracle.AskDeepThoughtRequest{
+					Data: req,
+				})
+				if err != nil {
+					errC <- err
+					return
+				}
+				if got, exp := resp.GetData(), req; got != exp {
+					errC <- fmt.Errorf("Got: '%v' Expected: '%v'", got, exp)
+				}
+			}(i)
+		}
+		wg.Wait()
+		close(errC)
+		for err := range errC {
+			t.Fatal(err)
+		}
+	})
 }
 
 func TestGRPCServerStart(t *testing.T) {
@@ -143,3 +197,419 @@ func TestGRPCServerStart(t *testing.T) {
 		}
 	})
 }
+
+func TestGRPCServerStream(t *testing.T) {
+	svr, err := NewGRPCServerPort((*stream.StreamerServer)(nil), ":9992")
+	if err != nil {
+		t.Fatal("failed to create grpc server port: ", err)
+	}
+	conn, err := grpc.Dial("localhost:9992", grpc.WithInsecure())
+	if err != nil {
+		t.Fatal("fialed to dial streamer address: ", err)
+	}
+	defer conn.Close()
+	client := stream.NewStreamerClient(conn)
+
+	t.Run("ServerStream", func(t *testing.T) {
+		go func() {
+			svr.Receive(t, &stream.StreamRequest{
+				Data: "count",
+			})
+			for _, v := range []string{"1", "2", "3"} {
+				svr.Send(t, &stream.StreamResponse{
+					Data: v,
+				})
+			}
+			svr.Send(t, &GRPCStreamEnd{})
+		}()
+
+		sc, err := client.ServerStream(context.Background(), &stream.StreamRequest{
+			Data: "count",
+		})
+		if err != nil {
+			t.Fatal("failed to open stream: ", err)
+		}
+		for _, exp := range []string{"1", "2", "3"} {
+			resp, err := sc.Recv()
+			if err != nil {
+				t.Fatal("failed to receive stream message: ", err)
+			}
+			if got := resp.GetData(); got != exp {
+				t.Fatalf("Got: '%v' Expected: '%v'", got, exp)
+			}
+		}
+		if _, err := sc.Recv(); err != io.EOF {
+			t.Fatalf("Got: '%v' Expected: '%v'", err, io.EOF)
+		}
+	})
+
+	t.Run("ClientStream", func(t *testing.T) {
+		go func() {
+			for _, v := range []string{"1", "2"} {
+				svr.Receive(t, &stream.StreamRequest{
+					Data: v,
+				})
+			}
+			svr.Receive(t, &GRPCCloseSend{})
+			svr.Send(t, &stream.StreamResponse{
+				Data: "3",
+			})
+			svr.Send(t, &GRPCStreamEnd{})
+		}()
+
+		sc, err := client.ClientStream(context.Background())
+		if err != nil {
+			t.Fatal("failed to open stream: ", err)
+		}
+		for _, v := range []string{"1", "2"} {
+			if err := sc.Send(&stream.StreamRequest{Data: v}); err != nil {
+				t.Fatal("failed to send stream message: ", err)
+			}
+		}
+		resp, err := sc.CloseAndRecv()
+		if err != nil {
+			t.Fatal("failed to close stream: ", err)
+		}
+		if got, exp := resp.GetData(), "3"; got != exp {
+			t.Fatalf("Got: '%v' Expected: '%v'", got, exp)
+		}
+	})
+
+	t.Run("BidiStreamCloseSend", func(t *testing.T) {
+		go func() {
+			n := 0
+			for {
+				call, _ := svr.Receive(t, match.Any(
+					match.Type(&stream.StreamRequest{}),
+					match.Type(&GRPCCloseSend{}),
+				))
+				if _, ok := call.Msg.(*GRPCCloseSend); ok {
+					break
+				}
+				n++
+			}
+			svr.Send(t, &stream.StreamResponse{
+				Data: fmt.Sprint(n),
+			})
+			svr.Send(t, &GRPCStreamEnd{})
+		}()
+
+		sc, err := client.BidiStream(context.Background())
+		if err != nil {
+			t.Fatal("failed to open stream: ", err)
+		}
+		for _, v := range []string{"1", "2", "3"} {
+			if err := sc.Send(&stream.StreamRequest{Data: v}); err != nil {
+				t.Fatal("failed to send stream message: ", err)
+			}
+		}
+		if err := sc.CloseSend(); err != nil {
+			t.Fatal("failed to close stream: ", err)
+		}
+		resp, err := sc.Recv()
+		if err != nil {
+			t.Fatal("failed to receive stream message: ", err)
+		}
+		if got, exp := resp.GetData(), "3"; got != exp {
+			t.Fatalf("Got: '%v' Expected: '%v'", got, exp)
+		}
+		if _, err := sc.Recv(); err != io.EOF {
+			t.Fatalf("Got: '%v' Expected: '%v'", err, io.EOF)
+		}
+	})
+
+	t.Run("BidiStreamEndWithStatus", func(t *testing.T) {
+		go func() {
+			svr.Receive(t, &stream.StreamRequest{
+				Data: "ping",
+			})
+			svr.Send(t, &stream.StreamResponse{
+				Data: "pong",
+			})
+			svr.Send(t, &GRPCStreamEnd{
+				Err: status.Error(codes.Aborted, "stream aborted"),
+			})
+		}()
+
+		sc, err := client.BidiStream(context.Background())
+		if err != nil {
+			t.Fatal("failed to open stream: ", err)
+		}
+		if err := sc.Send(&stream.StreamRequest{Data: "ping"}); err != nil {
+			t.Fatal("failed to send stream message: ", err)
+		}
+		resp, err := sc.Recv()
+		if err != nil {
+			t.Fatal("failed to receive stream message: ", err)
+		}
+		if got, exp := resp.GetData(), "pong"; got != exp {
+			t.Fatalf("Got: '%v' Expected: '%v'", got, exp)
+		}
+		_, err = sc.Recv()
+		if got, exp := status.Code(err), codes.Aborted; got != exp {
+			t.Fatalf("Got: '%v' Expected: '%v'", got, exp)
+		}
+	})
+}
+
+func TestGRPCServers(t *testing.T) {
+	svr, err := NewGRPCServersPort([]interface{}{
+		(*oracle.OracleServer)(nil),
+		(*stream.StreamerServer)(nil),
+	}, ":9994")
+	if err != nil {
+		t.Fatal("failed to create grpc server port: ", err)
+	}
+	conn, err := grpc.Dial("localhost:9994", grpc.WithInsecure())
+	if err != nil {
+		t.Fatal("fialed to dial address: ", err)
+	}
+	defer conn.Close()
+
+	go func() {
+		svr.Receive(t, &oracle.AskDeepThoughtRequest{
+			Data: "Ultimate question",
+		})
+		svr.Send(t, &oracle.AskDeepThoughtResponse{
+			Data: "42",
+		})
+		svr.Receive(t, &stream.StreamRequest{
+			Data: "count",
+		})
+		svr.Send(t, &GRPCStreamEnd{})
+	}()
+
+	resp, err := oracle.NewOracleClient(conn).AskDeepThought(context.Background(), &oracle.AskDeepThoughtRequest{
+		Data: "Ultimate question",
+	})
+	if err != nil {
+		t.Fatal("faield to ask deep through: ", err)
+	}
+	if got, exp := resp.GetData(), "42"; got != exp {
+		t.Fatalf("Got: '%v' Expected: '%v'", got, exp)
+	}
+
+	sc, err := stream.NewStreamerClient(conn).ServerStream(context.Background(), &stream.StreamRequest{
+		Data: "count",
+	})
+	if err != nil {
+		t.Fatal("failed to open stream: ", err)
+	}
+	if _, err := sc.Recv(); err != io.EOF {
+		t.Fatalf("Got: '%v' Expected: '%v'", err, io.EOF)
+	}
+}
+
+func TestGRPCServerReceiveOptions(t *testing.T) {
+	svr, err := NewGRPCServersPort([]interface{}{
+		(*oracle.OracleServer)(nil),
+		(*stream.StreamerServer)(nil),
+	}, ":9997")
+	if err != nil {
+		t.Fatal("failed to create grpc server port: ", err)
+	}
+	conn, err := grpc.Dial("localhost:9997", grpc.WithInsecure())
+	if err != nil {
+		t.Fatal("fialed to dial address: ", err)
+	}
+	defer conn.Close()
+
+	sc, err := stream.NewStreamerClient(conn).ServerStream(context.Background(), &stream.StreamRequest{
+		Data: "count",
+	})
+	if err != nil {
+		t.Fatal("failed to open stream: ", err)
+	}
+	respC := make(chan *oracle.AskDeepThoughtResponse, 1)
+	go func() {
+		// Make sure the stream message is queued before the oracle call.
+		time.Sleep(time.Millisecond * 100)
+		resp, _ := oracle.NewOracleClient(conn).AskDeepThought(context.Background(), &oracle.AskDeepThoughtRequest{
+			Data: "Ultimate question",
+		})
+		respC <- resp
+	}()
+
+	svr.Receive(t, &oracle.AskDeepThoughtRequest{
+		Data: "Ultimate question",
+	}, WithMethod("AskDeepThought"))
+	svr.Send(t, &oracle.AskDeepThoughtResponse{
+		Data: "42",
+	})
+	if got, exp := (<-respC).GetData(), "42"; got != exp {
+		t.Fatalf("Got: '%v' Expected: '%v'", got, exp)
+	}
+
+	svr.Receive(t, &stream.StreamRequest{
+		Data: "count",
+	}, WithMessageType(&stream.StreamRequest{}))
+	svr.Send(t, &GRPCStreamEnd{})
+	if _, err := sc.Recv(); err != io.EOF {
+		t.Fatalf("Got: '%v' Expected: '%v'", err, io.EOF)
+	}
+}
+
+func TestGRPCMetadata(t *testing.T) {
+	svr, err := NewGRPCServerPort((*oracle.OracleServer)(nil), ":9998")
+	if err != nil {
+		t.Fatal("failed to create grpc server port: ", err)
+	}
+	client, err := NewGRPCClientPort((*oracle.OracleClient)(nil), "localhost:9998")
+	if err != nil {
+		t.Fatal("failed to create grpc client port: ", err)
+	}
+
+	client.Send(t, &oracle.AskDeepThoughtRequest{
+		Data: "Ultimate question",
+	}, WithMetadata(metadata.Pairs("authorization", "Bearer token", "x-request-id", "42")))
+
+	call, _ := svr.Receive(t, &oracle.AskDeepThoughtRequest{
+		Data: "Ultimate question",
+	})
+	if got, exp := call.Metadata.Get("authorization"), []string{"Bearer token"}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("Got: '%v' Expected: '%v'", got, exp)
+	}
+	if got, exp := call.Metadata.Get("x-request-id"), []string{"42"}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("Got: '%v' Expected: '%v'", got, exp)
+	}
+
+	call.Send(t, &oracle.AskDeepThoughtResponse{
+		Data: "42",
+	}, WithHeader(metadata.Pairs("x-header", "h")), WithTrailer(metadata.Pairs("x-trailer", "t")))
+
+	resp, _ := client.Receive(t, &oracle.AskDeepThoughtResponse{
+		Data: "42",
+	})
+	if got, exp := resp.Header.Get("x-header"), []string{"h"}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("Got: '%v' Expected: '%v'", got, exp)
+	}
+	if got, exp := resp.Trailer.Get("x-trailer"), []string{"t"}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("Got: '%v' Expected: '%v'", got, exp)
+	}
+}
+
+func TestGRPCErrDetails(t *testing.T) {
+	svr, err := NewGRPCServerPort((*oracle.OracleServer)(nil), ":9989")
+	if err != nil {
+		t.Fatal("failed to create grpc server port: ", err)
+	}
+	client, err := NewGRPCClientPort((*oracle.OracleClient)(nil), "localhost:9989")
+	if err != nil {
+		t.Fatal("failed to create grpc client port: ", err)
+	}
+	retry := &errdetails.RetryInfo{
+		RetryDelay: ptypes.DurationProto(time.Second),
+	}
+
+	client.Send(t, &oracle.AskDeepThoughtRequest{
+		Data: "Ultimate question",
+	})
+	svr.Receive(t, &oracle.AskDeepThoughtRequest{
+		Data: "Ultimate question",
+	})
+	svr.Send(t, &GRPCErr{
+		Err:     status.Error(codes.Unavailable, "try later"),
+		Details: []proto.Message{retry},
+	})
+	client.Receive(t, match.GRPCErr(codes.Unavailable, "try later").WithDetails(retry))
+}
+
+func TestGRPCDelay(t *testing.T) {
+	svr, err := NewGRPCServerPort((*oracle.OracleServer)(nil), ":9988")
+	if err != nil {
+		t.Fatal("failed to create grpc server port: ", err)
+	}
+	client, err := NewGRPCClientPort((*oracle.OracleClient)(nil), "localhost:9988")
+	if err != nil {
+		t.Fatal("failed to create grpc client port: ", err)
+	}
+
+	t.Run("Delay", func(t *testing.T) {
+		client.Send(t, &oracle.AskDeepThoughtRequest{
+			Data: "Ultimate question",
+		})
+		call, _ := svr.Receive(t, &oracle.AskDeepThoughtRequest{
+			Data: "Ultimate question",
+		})
+		if !call.Deadline.IsZero() {
+			t.Fatalf("Got deadline '%v' Expected none", call.Deadline)
+		}
+
+		delay := 200 * time.Millisecond
+		start := time.Now()
+		call.Send(t, &oracle.AskDeepThoughtResponse{
+			Data: "42",
+		}, WithDelay(delay))
+		client.Receive(t, &oracle.AskDeepThoughtResponse{
+			Data: "42",
+		})
+		if elapsed := time.Since(start); elapsed < delay {
+			t.Fatalf("Got response after %v Expected at least %v", elapsed, delay)
+		}
+	})
+
+	t.Run("DelayUntilDeadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		defer cancel()
+		deadline, _ := ctx.Deadline()
+
+		client.Send(t, &oracle.AskDeepThoughtRequest{
+			Data: "Ultimate question",
+		}, WithCtx(ctx))
+		call, _ := svr.Receive(t, &oracle.AskDeepThoughtRequest{
+			Data: "Ultimate question",
+		})
+		if d := deadline.Sub(call.Deadline); d < -50*time.Millisecond || d > 50*time.Millisecond {
+			t.Fatalf("Got deadline '%v' Expected: '%v'", call.Deadline, deadline)
+		}
+
+		call.Send(t, &oracle.AskDeepThoughtResponse{
+			Data: "42",
+		}, WithDelayUntilDeadline())
+		client.Receive(t, match.GRPCErr(codes.DeadlineExceeded, "context deadline exceeded"))
+	})
+}
+
+func TestGRPCMTLS(t *testing.T) {
+	genCerts(t)
+	svr, err := NewGRPCServerPort((*oracle.OracleServer)(nil), ":9987", WithMTLS())
+	if err != nil {
+		t.Fatal("failed to create grpc server port: ", err)
+	}
+
+	t.Run("ClientCert", func(t *testing.T) {
+		client, err := NewGRPCClientPort((*oracle.OracleClient)(nil), "localhost:9987", WithMTLS())
+		if err != nil {
+			t.Fatal("failed to create grpc client port: ", err)
+		}
+		client.Send(t, &oracle.AskDeepThoughtRequest{
+			Data: "Ultimate question",
+		})
+		call, _ := svr.Receive(t, &oracle.AskDeepThoughtRequest{
+			Data: "Ultimate question",
+		})
+		if call.PeerCert == nil {
+			t.Fatalf("PeerCert not set")
+		}
+		if got, exp := call.PeerCert.Subject.CommonName, "mtf-client"; got != exp {
+			t.Fatalf("Got: '%v' Expected: '%v'", got, exp)
+		}
+		call.Send(t, &oracle.AskDeepThoughtResponse{
+			Data: "42",
+		})
+		client.Receive(t, &oracle.AskDeepThoughtResponse{
+			Data: "42",
+		})
+	})
+
+	t.Run("NoClientCert", func(t *testing.T) {
+		client, err := NewGRPCClientPort((*oracle.OracleClient)(nil), "localhost:9987", WithTLS())
+		if err != nil {
+			t.Fatal("failed to create grpc client port: ", err)
+		}
+		client.Send(t, &oracle.AskDeepThoughtRequest{
+			Data: "Ultimate question",
+		})
+		client.Receive(t, match.GRPCStatusCode(codes.Unavailable))
+	})
+}
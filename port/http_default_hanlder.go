@@ -2,39 +2,93 @@ package port
 
 import (
 	"context"
+	"crypto/x509"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
+
+	"github.com/smallinsky/mtf/match"
 )
 
-func NewHTTPPort() *Port {
+// NewHTTPPort returns port of HTTP and HTTPS server. With WithMTLS option
+// HTTPS clients have to present certificate signed by the mtf CA.
+// WithHost and WithPathPrefix options create port receiving only the
+// matching requests, port without them receives all other requests.
+func NewHTTPPort(opts ...PortOpt) *Port {
+	options := defaultPortOpts
+	for _, o := range opts {
+		o(&options)
+	}
 	startHTTP()
-	return &Port{
-		impl: ht.httpPort,
+	var p *HTTPPort
+	if options.httpHost == "" && options.httpPathPrefix == "" {
+		p = ht.useDefaultPort()
+	} else {
+		p = ht.routePort(httpRoute{
+			host:       options.httpHost,
+			pathPrefix: options.httpPathPrefix,
+		})
 	}
+	// Default and same route ports share HTTPPort, port created without
+	// WithMTLS doesn't turn off client certificate check of earlier one.
+	if options.serverClientCAPath != "" {
+		atomic.StoreInt32(&p.requireClientCert, 1)
+	}
+	return newPort(p)
 }
 
 func NewGCSPort() (*Port, error) {
 	startHTTP()
-	return &Port{
-		impl: ht.gcs,
-	}, nil
+	return newPort(ht.gcs), nil
 }
 func newHTTPPort() *HTTPPort {
 	return &HTTPPort{
-		reqC:  make(chan *HTTPRequest),
-		respC: make(chan *HTTPResponse),
+		reqC:  make(chan *httpCall),
+		stubs: newStubSet(),
 	}
 }
 
 type HTTPPort struct {
-	reqC  chan *HTTPRequest
+	reqC chan *httpCall
+
+	mtx sync.Mutex
+	// call is the last received request, target of port Send.
+	call *httpCall
+	// pending holds requeued requests received before new ones.
+	pending []*httpCall
+
+	stubs *stubSet
+
+	// requireClientCert is set to 1 when requests have to be sent over
+	// HTTPS with client certificate signed by the mtf CA.
+	requireClientCert int32
+}
+
+// httpCall is received HTTP request waiting for its response.
+type httpCall struct {
+	req   *HTTPRequest
 	respC chan *HTTPResponse
-	sync  chan struct{}
+}
+
+func (c *httpCall) send(ctx context.Context, msg interface{}) error {
+	resp, ok := msg.(*HTTPResponse)
+	if !ok {
+		return errors.Errorf("invalid type %T", msg)
+	}
+	resp.setDefaults()
+	select {
+	case c.respC <- resp:
+		return nil
+	default:
+		return errors.Errorf("response for the request already sent")
+	}
 }
 
 type HTTPRequest struct {
@@ -43,11 +97,62 @@ type HTTPRequest struct {
 	Method string
 	Host   string
 	URL    string
+	// PeerCert is verified client certificate of HTTPS request.
+	PeerCert *x509.Certificate
+
+	Header  http.Header
+	Query   url.Values
+	Cookies []*http.Cookie
+}
+
+// reduce returns copy of received request with Header, Query, Cookies
+// and PeerCert limited to the fields set in exp. Only headers listed
+// in exp are kept, since HTTP clients add their own headers.
+func (r *HTTPRequest) reduce(exp *HTTPRequest) *HTTPRequest {
+	out := *r
+	out.Header = nil
+	if exp.Header != nil {
+		out.Header = make(http.Header)
+		for k := range exp.Header {
+			if v, ok := r.Header[http.CanonicalHeaderKey(k)]; ok {
+				out.Header[k] = v
+			}
+		}
+	}
+	if exp.Query == nil {
+		out.Query = nil
+	}
+	if exp.Cookies == nil {
+		out.Cookies = nil
+	}
+	if exp.PeerCert == nil {
+		out.PeerCert = nil
+	}
+	return &out
+}
+
+// httpRequestMatcher compares received request fields set in exp.
+type httpRequestMatcher struct {
+	exp *HTTPRequest
+}
+
+func (m *httpRequestMatcher) MatchReceived(err error, got interface{}) error {
+	if r, ok := got.(*HTTPRequest); ok {
+		got = r.reduce(m.exp)
+	}
+	return match.DeepEqual(m.exp).MatchReceived(err, got)
+}
+
+func (m *httpRequestMatcher) FailureMessage(got interface{}, err error) string {
+	return match.DeepEqual(m.exp).FailureMessage(got, err)
 }
 
 type HTTPResponse struct {
 	Body   []byte
 	Status int
+
+	Header  http.Header
+	Trailer http.Header
 }
 
 func convHTTPRequest(r *http.Request) *HTTPRequest {
@@ -66,6 +171,13 @@ func convHTTPRequest(r *http.Request) *HTTPRequest {
 		Body:   buff,
 		Host:   r.Host,
 		URL:    r.URL.RequestURI(),
+
+		Header:  r.Header,
+		Query:   r.URL.Query(),
+		Cookies: r.Cookies(),
+	}
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		out.PeerCert = r.TLS.VerifiedChains[0][0]
 	}
 
 	if len(out.Body) == 0 {
@@ -81,43 +193,104 @@ func (resp *HTTPResponse) setDefaults() {
 	}
 }
 
+func (p *HTTPPort) stubSet() *stubSet {
+	return p.stubs
+}
+
 func (p *HTTPPort) Register(router *mux.Router) {
 	router.NotFoundHandler = p
 }
 
 func (p *HTTPPort) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	p.reqC <- convHTTPRequest(req)
+	if atomic.LoadInt32(&p.requireClientCert) == 1 && (req.TLS == nil || len(req.TLS.VerifiedChains) == 0) {
+		http.Error(w, "mtf: client certificate required", http.StatusForbidden)
+		return
+	}
+	call := &httpCall{
+		req:   convHTTPRequest(req),
+		respC: make(chan *HTTPResponse, 1),
+	}
+	if stub, ok := p.stubs.reply(call.req); ok {
+		if err := call.send(req.Context(), stub); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else {
+		select {
+		case p.reqC <- call:
+		case <-req.Context().Done():
+			return
+		}
+	}
 
-	resp := <-p.respC
+	var resp *HTTPResponse
+	select {
+	case resp = <-call.respC:
+	case <-req.Context().Done():
+		return
+	}
+	for k, v := range resp.Header {
+		w.Header()[http.CanonicalHeaderKey(k)] = v
+	}
+	for k := range resp.Trailer {
+		w.Header().Add("Trailer", k)
+	}
 	w.WriteHeader(resp.Status)
 	w.Write([]byte(resp.Body))
+	for k, v := range resp.Trailer {
+		w.Header()[http.CanonicalHeaderKey(k)] = v
+	}
 }
 
 func (p *HTTPPort) receive(opts ...Opt) (*HTTPRequest, error) {
+	call, err := p.receiveCall(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return call.req, nil
+}
+
+// receiveCall returns next request and makes it the target of port Send.
+func (p *HTTPPort) receiveCall(opts ...Opt) (*httpCall, error) {
 	options := defaultPortOpts
 	for _, o := range opts {
 		o(&options)
 	}
 
+	p.mtx.Lock()
+	if len(p.pending) > 0 {
+		call := p.pending[0]
+		p.pending = p.pending[1:]
+		p.call = call
+		p.mtx.Unlock()
+		return call, nil
+	}
+	p.mtx.Unlock()
+
+	timer := time.NewTimer(options.timeout)
+	defer timer.Stop()
 	select {
-	case req := <-p.reqC:
-		return req, nil
-	case <-time.Tick(options.timeout):
+	case call := <-p.reqC:
+		p.mtx.Lock()
+		p.call = call
+		p.mtx.Unlock()
+		return call, nil
+	case <-timer.C:
 		return nil, errors.Errorf("failed to receive  message, deadline exeeded")
+	case <-options.done():
+		return nil, errors.Wrapf(options.ctx.Err(), "failed to receive message")
 	}
 }
 
+// send replies to the last received request.
 func (p *HTTPPort) send(msg *HTTPResponse, opts ...Opt) error {
-	options := defaultPortOpts
-	for _, opt := range opts {
-		opt(&options)
+	p.mtx.Lock()
+	call := p.call
+	p.mtx.Unlock()
+	if call == nil {
+		return errors.Errorf("no request received")
 	}
-
-	msg.setDefaults()
-	go func() {
-		p.respC <- msg
-	}()
-	return nil
+	return call.send(context.Background(), msg)
 }
 
 func (p *HTTPPort) Send(ctx context.Context, i interface{}) error {
@@ -128,6 +301,47 @@ func (p *HTTPPort) Send(ctx context.Context, i interface{}) error {
 	return p.send(resp)
 }
 
+// Receive returns *Call with received *HTTPRequest, the call Send
+// replies to this request.
 func (p *HTTPPort) Receive(ctx context.Context) (interface{}, error) {
-	return p.receive()
+	p.mtx.Lock()
+	prev := p.call
+	p.mtx.Unlock()
+	call, err := p.receiveCall(withContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return &Call{
+		Msg:  call.req,
+		send: call.send,
+		value: acceptedHTTP{
+			call: call,
+			prev: prev,
+		},
+	}, nil
+}
+
+// acceptedHTTP is received request with send target it replaced.
+type acceptedHTTP struct {
+	call *httpCall
+	prev *httpCall
+}
+
+// requeue puts received request back for the next receive call and
+// restores the previous send target.
+func (p *HTTPPort) requeue(m interface{}, err error) {
+	call, _ := m.(*Call)
+	if call == nil {
+		return
+	}
+	a, ok := call.value.(acceptedHTTP)
+	if !ok {
+		return
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.pending = append([]*httpCall{a.call}, p.pending...)
+	if p.call == a.call {
+		p.call = a.prev
+	}
 }
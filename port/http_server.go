@@ -1,13 +1,15 @@
 package port
 
 import (
+	"crypto/tls"
+	"fmt"
 	"log"
 	"net/http"
 	"runtime"
+	"sort"
 	"sync"
 
 	"github.com/gorilla/mux"
-	"github.com/smallinsky/mtf/pkg/cert"
 )
 
 var (
@@ -21,6 +23,20 @@ type httpserver struct {
 
 	httpPort *HTTPPort
 	gcs      *GCStorage
+
+	mtx sync.Mutex
+	// routes holds HTTP ports bound to host and path prefix, portRouter
+	// dispatches requests to them ordered from the most specific route.
+	routes     map[httpRoute]*HTTPPort
+	portRouter *mux.Router
+	// defaultPort is set when httpPort receiving not routed requests
+	// was created by NewHTTPPort.
+	defaultPort bool
+}
+
+type httpRoute struct {
+	host       string
+	pathPrefix string
 }
 
 func startHTTP() {
@@ -29,8 +45,10 @@ func startHTTP() {
 			router:   mux.NewRouter(),
 			httpPort: newHTTPPort(),
 			gcs:      NewGCStoragePort(),
+			routes:   make(map[httpRoute]*HTTPPort),
 		}
-		ht.httpPort.Register(ht.router)
+		ht.portRouter = ht.newPortRouter()
+		ht.router.NotFoundHandler = http.HandlerFunc(ht.routeRequest)
 		if ht.gcs != nil {
 			ht.gcs.registerRouter(ht.router)
 		}
@@ -56,7 +74,16 @@ func (ht *httpserver) serveHTTPS() error {
 	ht.wg.Add(1)
 	go func() {
 		ht.wg.Done()
-		if err := http.ListenAndServeTLS(":8443", cert.ServerCertFile, cert.ServerKeyFile, ht.router); err != nil {
+		config, err := ht.tlsConfig()
+		if err != nil {
+			log.Fatalf("failed to load tls config: %v", err)
+		}
+		srv := &http.Server{
+			Addr:      ":8443",
+			Handler:   ht.router,
+			TLSConfig: config,
+		}
+		if err := srv.ListenAndServeTLS("", ""); err != nil {
 			log.Fatalf("faield to start tls server: %v", err)
 		}
 	}()
@@ -64,6 +91,97 @@ func (ht *httpserver) serveHTTPS() error {
 	return nil
 }
 
+// routePort returns HTTP port receiving requests matching the route,
+// the port is created and registered on the router on first use.
+func (ht *httpserver) routePort(route httpRoute) *HTTPPort {
+	ht.mtx.Lock()
+	defer ht.mtx.Unlock()
+	if p, ok := ht.routes[route]; ok {
+		return p
+	}
+	p := newHTTPPort()
+	ht.routes[route] = p
+	ht.portRouter = ht.newPortRouter()
+	return p
+}
+
+// newPortRouter returns router of HTTP ports routes. Router uses the first
+// matching route, so routes with longer path prefix go first and routes
+// with host go before the ones without it.
+func (ht *httpserver) newPortRouter() *mux.Router {
+	routes := make([]httpRoute, 0, len(ht.routes))
+	for route := range ht.routes {
+		routes = append(routes, route)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		a, b := routes[i], routes[j]
+		if len(a.pathPrefix) != len(b.pathPrefix) {
+			return len(a.pathPrefix) > len(b.pathPrefix)
+		}
+		if (a.host != "") != (b.host != "") {
+			return a.host != ""
+		}
+		return a.host < b.host
+	})
+
+	router := mux.NewRouter()
+	for _, route := range routes {
+		r := router.NewRoute()
+		if route.host != "" {
+			r = r.Host(route.host)
+		}
+		if route.pathPrefix != "" {
+			r = r.PathPrefix(route.pathPrefix)
+		}
+		r.Handler(ht.routes[route])
+	}
+	router.NotFoundHandler = http.HandlerFunc(ht.notFound)
+	return router
+}
+
+// routeRequest passes requests not handled by GCS port to HTTP ports.
+func (ht *httpserver) routeRequest(w http.ResponseWriter, r *http.Request) {
+	ht.mtx.Lock()
+	router := ht.portRouter
+	ht.mtx.Unlock()
+	router.ServeHTTP(w, r)
+}
+
+func (ht *httpserver) useDefaultPort() *HTTPPort {
+	ht.mtx.Lock()
+	defer ht.mtx.Unlock()
+	ht.defaultPort = true
+	return ht.httpPort
+}
+
+// notFound passes requests not matching any route to the default HTTP
+// port. If only routed ports are used the request fails with an error.
+func (ht *httpserver) notFound(w http.ResponseWriter, r *http.Request) {
+	ht.mtx.Lock()
+	fallback := ht.defaultPort || len(ht.routes) == 0
+	ht.mtx.Unlock()
+	if fallback {
+		ht.httpPort.ServeHTTP(w, r)
+		return
+	}
+	msg := fmt.Sprintf("mtf: no HTTP port for %s %s%s", r.Method, r.Host, r.URL.Path)
+	log.Println(msg)
+	http.Error(w, msg, http.StatusNotFound)
+}
+
+// tlsConfig returns HTTPS server config. Client certificates are verified
+// if given, ports created with WithMTLS reject requests without them.
+func (ht *httpserver) tlsConfig() (*tls.Config, error) {
+	options := defaultPortOpts
+	WithMTLS()(&options)
+	config, err := options.serverTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	return config, nil
+}
+
 func (ht *httpserver) servHTTP() error {
 	ht.wg.Add(1)
 	go func() {
@@ -2,19 +2,41 @@ package port
 
 import (
 	"context"
+	"crypto/tls"
+	"io/ioutil"
 	"net/http"
+	"net/url"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/smallinsky/mtf/match"
 	"github.com/smallinsky/mtf/pkg/cert"
 )
 
-func TestHTTPServer(t *testing.T) {
-	if _, err := cert.GenCert(nil); err != nil {
-		t.Fatalf("failed to generate certs: %v", err)
+var (
+	certOnce sync.Once
+	certErr  error
+)
+
+// genCerts generates mtf CA and client certs once, HTTPS server loads
+// the CA only on start.
+func genCerts(t *testing.T) {
+	certOnce.Do(func() {
+		if _, certErr = cert.GenCert(nil); certErr != nil {
+			return
+		}
+		_, certErr = cert.GenClientCert("mtf-client")
+	})
+	if certErr != nil {
+		t.Fatalf("failed to generate certs: %v", certErr)
 	}
+}
+
+func TestHTTPServer(t *testing.T) {
+	genCerts(t)
 	startHTTP()
-	port := ht.httpPort
+	port := ht.useDefaultPort()
 
 	sync := make(chan struct{})
 	go func() {
@@ -56,3 +78,325 @@ func TestHTTPServer(t *testing.T) {
 	}
 
 }
+
+func TestHTTPServerMTLS(t *testing.T) {
+	genCerts(t)
+	port := NewHTTPPort(WithMTLS(), WithPathPrefix("/mtls"))
+
+	options := defaultPortOpts
+	WithMTLS()(&options)
+	config, err := options.clientTLSConfig("localhost")
+	if err != nil {
+		t.Fatalf("failed to load client tls config: %v", err)
+	}
+
+	t.Run("ClientCert", func(t *testing.T) {
+		client := &http.Client{
+			Transport: &http.Transport{TLSClientConfig: config},
+			Timeout:   time.Second,
+		}
+		errC := make(chan error, 1)
+		go func() {
+			resp, err := client.Get("https://localhost:8443/mtls")
+			if err == nil {
+				resp.Body.Close()
+			}
+			errC <- err
+		}()
+
+		req, err := port.Receive(t, match.Type(&HTTPRequest{}))
+		if err != nil {
+			t.Fatalf("failed to receive request: %v", err)
+		}
+		m := req.Msg.(*HTTPRequest)
+		if m.PeerCert == nil {
+			t.Fatalf("PeerCert not set")
+		}
+		if want, got := "mtf-client", m.PeerCert.Subject.CommonName; want != got {
+			t.Fatalf("CommonName want: %v, got %v", want, got)
+		}
+		port.Send(t, &HTTPResponse{})
+		if err := <-errC; err != nil {
+			t.Fatalf("http call failed: %v", err)
+		}
+	})
+
+	t.Run("NoClientCert", func(t *testing.T) {
+		client := &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: config.RootCAs}},
+			Timeout:   time.Second,
+		}
+		resp, err := client.Get("https://localhost:8443/mtls")
+		if err != nil {
+			t.Fatalf("http call failed: %v", err)
+		}
+		resp.Body.Close()
+		if want, got := http.StatusForbidden, resp.StatusCode; want != got {
+			t.Fatalf("StatusCode want: %v, got %v", want, got)
+		}
+	})
+
+	t.Run("SameRouteNoMTLS", func(t *testing.T) {
+		NewHTTPPort(WithPathPrefix("/mtls"))
+		client := &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: config.RootCAs}},
+			Timeout:   time.Second,
+		}
+		resp, err := client.Get("https://localhost:8443/mtls")
+		if err != nil {
+			t.Fatalf("http call failed: %v", err)
+		}
+		resp.Body.Close()
+		if want, got := http.StatusForbidden, resp.StatusCode; want != got {
+			t.Fatalf("StatusCode want: %v, got %v", want, got)
+		}
+	})
+
+	t.Run("OtherPortNoClientCert", func(t *testing.T) {
+		other := NewHTTPPort()
+		client := &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: config.RootCAs}},
+			Timeout:   time.Second,
+		}
+		errC := make(chan error, 1)
+		go func() {
+			resp, err := client.Get("https://localhost:8443/other")
+			if err == nil {
+				resp.Body.Close()
+			}
+			errC <- err
+		}()
+		other.Receive(t, &HTTPRequest{
+			Method: http.MethodGet,
+			Host:   "localhost:8443",
+			URL:    "/other",
+		})
+		other.Send(t, &HTTPResponse{})
+		if err := <-errC; err != nil {
+			t.Fatalf("http call failed: %v", err)
+		}
+	})
+}
+
+func TestHTTPServerHeaders(t *testing.T) {
+	genCerts(t)
+	port := NewHTTPPort()
+
+	req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/headers?q=42&page=1", nil)
+	if err != nil {
+		t.Fatalf("failed to crate http request: %v", err)
+	}
+	req.Header.Set("X-Api-Key", "secret")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+
+	type result struct {
+		resp *http.Response
+		body []byte
+		err  error
+	}
+	resC := make(chan result, 1)
+	go func() {
+		var r result
+		r.resp, r.err = (&http.Client{Timeout: time.Second}).Do(req)
+		if r.err == nil {
+			r.body, r.err = ioutil.ReadAll(r.resp.Body)
+			r.resp.Body.Close()
+		}
+		resC <- r
+	}()
+
+	port.Receive(t, &HTTPRequest{
+		Method: http.MethodGet,
+		Host:   "localhost:8080",
+		URL:    "/headers?q=42&page=1",
+		Header: http.Header{
+			"X-Api-Key": []string{"secret"},
+		},
+		Query: url.Values{
+			"q":    []string{"42"},
+			"page": []string{"1"},
+		},
+		Cookies: []*http.Cookie{
+			{Name: "session", Value: "abc"},
+		},
+	})
+	port.Send(t, &HTTPResponse{
+		Status: http.StatusCreated,
+		Body:   []byte("ok"),
+		Header: http.Header{
+			"Content-Type": []string{"text/plain"},
+			"Location":     []string{"/headers/1"},
+		},
+		Trailer: http.Header{
+			"X-Checksum": []string{"1234"},
+		},
+	})
+
+	r := <-resC
+	if r.err != nil {
+		t.Fatalf("http call failed: %v", r.err)
+	}
+	if want, got := http.StatusCreated, r.resp.StatusCode; want != got {
+		t.Fatalf("StatusCode want: %v, got %v", want, got)
+	}
+	if want, got := "/headers/1", r.resp.Header.Get("Location"); want != got {
+		t.Fatalf("Location want: %v, got %v", want, got)
+	}
+	if want, got := "text/plain", r.resp.Header.Get("Content-Type"); want != got {
+		t.Fatalf("Content-Type want: %v, got %v", want, got)
+	}
+	if want, got := "1234", r.resp.Trailer.Get("X-Checksum"); want != got {
+		t.Fatalf("X-Checksum trailer want: %v, got %v", want, got)
+	}
+	if want, got := "ok", string(r.body); want != got {
+		t.Fatalf("Body want: %v, got %v", want, got)
+	}
+}
+
+func TestHTTPServerRoutes(t *testing.T) {
+	genCerts(t)
+	weather := NewHTTPPort(WithHost("api.weather.com"))
+	jokes := NewHTTPPort(WithHost("api.icndb.com"), WithPathPrefix("/jokes"))
+
+	// Disable default port enabled by other tests to check not routed requests.
+	ht.mtx.Lock()
+	defaultPort := ht.defaultPort
+	ht.defaultPort = false
+	ht.mtx.Unlock()
+	defer func() {
+		ht.mtx.Lock()
+		ht.defaultPort = defaultPort
+		ht.mtx.Unlock()
+	}()
+
+	get := func(host, path string) <-chan *http.Response {
+		respC := make(chan *http.Response, 1)
+		go func() {
+			req, _ := http.NewRequest(http.MethodGet, "http://localhost:8080"+path, nil)
+			req.Host = host
+			resp, err := (&http.Client{Timeout: time.Second}).Do(req)
+			if err != nil {
+				respC <- nil
+				return
+			}
+			resp.Body.Close()
+			respC <- resp
+		}()
+		return respC
+	}
+
+	jokesC := get("api.icndb.com", "/jokes/random")
+	weatherC := get("api.weather.com", "/forecast")
+
+	weather.Receive(t, &HTTPRequest{
+		Method: http.MethodGet,
+		Host:   "api.weather.com",
+		URL:    "/forecast",
+	})
+	weather.Send(t, &HTTPResponse{
+		Status: http.StatusAccepted,
+	})
+	jokes.Receive(t, &HTTPRequest{
+		Method: http.MethodGet,
+		Host:   "api.icndb.com",
+		URL:    "/jokes/random",
+	})
+	jokes.Send(t, &HTTPResponse{
+		Status: http.StatusCreated,
+	})
+
+	for _, tc := range []struct {
+		respC  <-chan *http.Response
+		status int
+	}{
+		{respC: weatherC, status: http.StatusAccepted},
+		{respC: jokesC, status: http.StatusCreated},
+		{respC: get("api.icndb.com", "/categories"), status: http.StatusNotFound},
+		{respC: get("example.com", "/"), status: http.StatusNotFound},
+	} {
+		resp := <-tc.respC
+		if resp == nil {
+			t.Fatalf("http call failed")
+		}
+		if want, got := tc.status, resp.StatusCode; want != got {
+			t.Fatalf("StatusCode want: %v, got %v", want, got)
+		}
+	}
+}
+
+func TestHTTPServerRoutesSpecificity(t *testing.T) {
+	genCerts(t)
+	// Broader route is created first, more specific one still gets its requests.
+	api := NewHTTPPort(WithHost("api.order.com"))
+	v2 := NewHTTPPort(WithHost("api.order.com"), WithPathPrefix("/v2"))
+
+	get := func(path string) <-chan error {
+		errC := make(chan error, 1)
+		go func() {
+			req, _ := http.NewRequest(http.MethodGet, "http://localhost:8080"+path, nil)
+			req.Host = "api.order.com"
+			resp, err := (&http.Client{Timeout: time.Second}).Do(req)
+			if err == nil {
+				resp.Body.Close()
+			}
+			errC <- err
+		}()
+		return errC
+	}
+
+	for _, tc := range []struct {
+		port *Port
+		path string
+	}{
+		{port: v2, path: "/v2/orders"},
+		{port: api, path: "/v1/orders"},
+	} {
+		errC := get(tc.path)
+		tc.port.Receive(t, &HTTPRequest{
+			Method: http.MethodGet,
+			Host:   "api.order.com",
+			URL:    tc.path,
+		})
+		tc.port.Send(t, &HTTPResponse{})
+		if err := <-errC; err != nil {
+			t.Fatalf("http call failed: %v", err)
+		}
+	}
+}
+
+func TestHTTPServerConcurrentRequests(t *testing.T) {
+	genCerts(t)
+	port := NewHTTPPort(WithHost("fanout.example.com"))
+
+	bodyC := make(map[string]chan string)
+	for _, path := range []string{"/first", "/second"} {
+		c := make(chan string, 1)
+		bodyC[path] = c
+		go func(path string) {
+			req, _ := http.NewRequest(http.MethodGet, "http://localhost:8080"+path, nil)
+			req.Host = "fanout.example.com"
+			resp, err := (&http.Client{Timeout: time.Second}).Do(req)
+			if err != nil {
+				c <- err.Error()
+				return
+			}
+			defer resp.Body.Close()
+			b, _ := ioutil.ReadAll(resp.Body)
+			c <- string(b)
+		}(path)
+	}
+
+	first, _ := port.Receive(t, match.Type(&HTTPRequest{}))
+	second, _ := port.Receive(t, match.Type(&HTTPRequest{}))
+	for _, call := range []*Call{second, first} {
+		call.Send(t, &HTTPResponse{
+			Body: []byte(call.Msg.(*HTTPRequest).URL),
+		})
+	}
+
+	for path, c := range bodyC {
+		if got := <-c; got != path {
+			t.Fatalf("Body want: %v, got %v", path, got)
+		}
+	}
+}
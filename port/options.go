@@ -1,9 +1,15 @@
 package port
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
+
 	"github.com/smallinsky/mtf/pkg/cert"
 )
 
@@ -28,12 +34,24 @@ func WithTimeout(timout time.Duration) Opt {
 }
 
 type portOpts struct {
+	// ctx is the receive context, receive is stopped once it's done.
+	ctx context.Context
+
 	clientCertPath string
+	// clientAuthCertPath and clientAuthKeyPath are client certificate
+	// presented by client port to mTLS server.
+	clientAuthCertPath string
+	clientAuthKeyPath  string
 
 	serverCertPath string
 	serverKeyPath  string
+	// serverClientCAPath is CA used by server port to verify required
+	// client certificates.
+	serverClientCAPath string
+
+	httpHost       string
+	httpPathPrefix string
 
-	pkgName string
 	err     error
 	timeout time.Duration
 
@@ -50,6 +68,109 @@ func WithTLS() PortOpt {
 	}
 }
 
+// WithMTLS enables mutual TLS. Server port requires client certificate
+// signed by the mtf CA, client port presents client certificate
+// generated by cert.GenClientCert.
+func WithMTLS() PortOpt {
+	return func(o *portOpts) {
+		WithTLS()(o)
+		o.serverClientCAPath = cert.ServerCertFile
+		if o.clientAuthCertPath == "" {
+			o.clientAuthCertPath = cert.ClientCertFile
+			o.clientAuthKeyPath = cert.ClientKeyFile
+		}
+	}
+}
+
+// WithClientCert sets client certificate presented by client port, it
+// allows to call mTLS server with different client identities.
+func WithClientCert(certPath, keyPath string) PortOpt {
+	return func(o *portOpts) {
+		o.clientAuthCertPath = certPath
+		o.clientAuthKeyPath = keyPath
+	}
+}
+
+// WithHost binds HTTP port to requests sent to host, mux host template
+// like "{subdomain}.example.com" is accepted.
+func WithHost(host string) PortOpt {
+	return func(o *portOpts) {
+		o.httpHost = host
+	}
+}
+
+// WithPathPrefix binds HTTP port to requests with URL path prefix.
+func WithPathPrefix(prefix string) PortOpt {
+	return func(o *portOpts) {
+		o.httpPathPrefix = prefix
+	}
+}
+
+func (o *portOpts) serverTLSConfig() (*tls.Config, error) {
+	crt, err := tls.LoadX509KeyPair(o.serverCertPath, o.serverKeyPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load server cert")
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{crt},
+	}
+	if o.serverClientCAPath != "" {
+		pool, err := loadCertPool(o.serverClientCAPath)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+func (o *portOpts) clientTLSConfig(serverName string) (*tls.Config, error) {
+	pool, err := loadCertPool(o.clientCertPath)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		RootCAs:    pool,
+		ServerName: serverName,
+	}
+	if o.clientAuthCertPath != "" {
+		crt, err := tls.LoadX509KeyPair(o.clientAuthCertPath, o.clientAuthKeyPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load client cert")
+		}
+		config.Certificates = []tls.Certificate{crt}
+	}
+	return config, nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read cert file %v", path)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, errors.Errorf("failed to append certs from %v", path)
+	}
+	return pool, nil
+}
+
+// withContext passes Receive context to port receive.
+func withContext(ctx context.Context) Opt {
+	return func(o *portOpts) {
+		o.ctx = ctx
+	}
+}
+
+// done returns receive context done channel, it's nil without context.
+func (o *portOpts) done() <-chan struct{} {
+	if o.ctx == nil {
+		return nil
+	}
+	return o.ctx.Done()
+}
+
 var defaultPortOpts = portOpts{
 	timeout: time.Second * 3,
 }
@@ -2,9 +2,16 @@ package port
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	mtfctx "github.com/smallinsky/mtf/framework/context"
 	"github.com/smallinsky/mtf/match"
@@ -19,8 +26,44 @@ type Port struct {
 	impl PortImpl
 }
 
+// registry holds ports created by the package constructors, suite
+// verifies them after each test method.
+var registry struct {
+	mtx   sync.Mutex
+	ports []*Port
+}
+
+// newPort returns port of impl and registers it. Ports sharing
+// implementation, like HTTP ports with the same route, are registered once.
+func newPort(impl PortImpl) *Port {
+	p := &Port{
+		impl: impl,
+	}
+	registry.mtx.Lock()
+	defer registry.mtx.Unlock()
+	for _, r := range registry.ports {
+		if r.impl == impl {
+			return p
+		}
+	}
+	registry.ports = append(registry.ports, p)
+	return p
+}
+
+// Ports returns ports created by the package constructors in creation order.
+func Ports() []*Port {
+	registry.mtx.Lock()
+	defer registry.mtx.Unlock()
+	return append([]*Port(nil), registry.ports...)
+}
+
 type sendOptions struct {
 	ctx context.Context
+
+	md metadata.MD
+
+	values sendValues
+	method string
 }
 
 type SendOption func(*sendOptions)
@@ -31,7 +74,154 @@ func WithCtx(ctx context.Context) SendOption {
 	}
 }
 
+// WithMetadata attaches outgoing grpc metadata to client port call.
+func WithMetadata(md metadata.MD) SendOption {
+	return func(o *sendOptions) {
+		o.md = metadata.Join(o.md, md)
+	}
+}
+
+// WithHeader sets grpc response header of server port call.
+func WithHeader(md metadata.MD) SendOption {
+	return func(o *sendOptions) {
+		o.values.header = metadata.Join(o.values.header, md)
+	}
+}
+
+// WithTrailer sets grpc response trailer of server port call.
+func WithTrailer(md metadata.MD) SendOption {
+	return func(o *sendOptions) {
+		o.values.trailer = metadata.Join(o.values.trailer, md)
+	}
+}
+
+// WithDelay delays the reply of server port call by d. The reply is
+// dropped if the caller context is done before the delay elapses.
+func WithDelay(d time.Duration) SendOption {
+	return func(o *sendOptions) {
+		o.values.delay = d
+	}
+}
+
+// WithDelayUntilDeadline holds the reply of server port call until the
+// caller context expires, which simulates a service that never answers.
+func WithDelayUntilDeadline() SendOption {
+	return func(o *sendOptions) {
+		o.values.untilDeadline = true
+	}
+}
+
+// ToMethod sends client port request to grpc method with the given name,
+// both "Method" and full "/package.Service/Method" names are accepted.
+// It is required when the request message is used by more than one method.
+func ToMethod(name string) SendOption {
+	return func(o *sendOptions) {
+		o.method = name
+	}
+}
+
+type sendValuesKey struct{}
+
+type sendMethodKey struct{}
+
+// getSendMethod returns client port method selected by ToMethod.
+func getSendMethod(ctx context.Context) string {
+	m, _ := ctx.Value(sendMethodKey{}).(string)
+	return m
+}
+
+// sendValues are server side send options passed to port
+// implementation with the send context.
+type sendValues struct {
+	header        metadata.MD
+	trailer       metadata.MD
+	delay         time.Duration
+	untilDeadline bool
+}
+
+// getSendValues returns server side options passed to port Send.
+func getSendValues(ctx context.Context) sendValues {
+	v, _ := ctx.Value(sendValuesKey{}).(sendValues)
+	return v
+}
+
+// wait blocks for the reply delay, it returns an error if ctx is done
+// before the reply can be sent.
+func (v sendValues) wait(ctx context.Context) error {
+	if v.untilDeadline {
+		<-ctx.Done()
+		return contextErr(ctx.Err())
+	}
+	if v.delay <= 0 {
+		return nil
+	}
+	t := time.NewTimer(v.delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return contextErr(ctx.Err())
+	}
+}
+
+// contextErr converts context error to grpc status error.
+func contextErr(err error) error {
+	if err == context.DeadlineExceeded {
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return status.Error(codes.Canceled, err.Error())
+}
+
+func (o *sendOptions) context() context.Context {
+	ctx := o.ctx
+	if o.md != nil {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		ctx = metadata.NewOutgoingContext(ctx, metadata.Join(md, o.md))
+	}
+	if v := o.values; v.header != nil || v.trailer != nil || v.delay > 0 || v.untilDeadline {
+		ctx = context.WithValue(ctx, sendValuesKey{}, o.values)
+	}
+	if o.method != "" {
+		ctx = context.WithValue(ctx, sendMethodKey{}, o.method)
+	}
+	return ctx
+}
+
+type receiveOptions struct {
+	method  string
+	msgType interface{}
+}
+
+type ReceiveOption func(*receiveOptions)
+
+// WithMethod receives only messages of grpc method with the given name,
+// both "Method" and full "/package.Service/Method" names are accepted.
+func WithMethod(name string) ReceiveOption {
+	return func(o *receiveOptions) {
+		o.method = name
+	}
+}
+
+// WithMessageType receives only messages of the same type as msg.
+func WithMessageType(msg interface{}) ReceiveOption {
+	return func(o *receiveOptions) {
+		o.msgType = msg
+	}
+}
+
+// selectiveReceiver is implemented by ports that queue received messages
+// and allow to receive only the ones selected by receive options.
+// Messages that were not selected wait in the port for other receive calls.
+type selectiveReceiver interface {
+	receiveSelected(ctx context.Context, opts receiveOptions) (interface{}, error)
+}
+
 func (p *Port) Send(t *testing.T, i interface{}, opts ...SendOption) error {
+	return p.send(t, p.impl.Send, i, opts...)
+}
+
+func (p *Port) send(t *testing.T, sendFn func(context.Context, interface{}) error, i interface{}, opts ...SendOption) error {
 	defOpts := &sendOptions{
 		ctx: context.Background(),
 	}
@@ -41,7 +231,7 @@ func (p *Port) Send(t *testing.T, i interface{}, opts ...SendOption) error {
 	}
 
 	name := getPortName(p.impl)
-	if err := p.impl.Send(defOpts.ctx, i); err != nil {
+	if err := sendFn(defOpts.context(), i); err != nil {
 		t.Fatalf("failed to send %T from %s, err: %v", i, name, err)
 	}
 
@@ -51,55 +241,128 @@ func (p *Port) Send(t *testing.T, i interface{}, opts ...SendOption) error {
 	return nil
 }
 
+// Call is a message received by port. Ports that handle calls made by SUT
+// allow to reply to the exact call that delivered the message, so
+// concurrent calls can be answered in any order.
+type Call struct {
+	Msg interface{}
+
+	// Metadata is incoming grpc metadata of call received by server port.
+	Metadata metadata.MD
+	// Deadline is incoming deadline of call received by server port,
+	// it is zero if the caller didn't set one.
+	Deadline time.Time
+	// PeerCert is verified client certificate of mTLS call received by
+	// server port.
+	PeerCert *x509.Certificate
+	// Header and Trailer are grpc response metadata of call made by client port.
+	Header  metadata.MD
+	Trailer metadata.MD
+
+	port *Port
+	send func(ctx context.Context, msg interface{}) error
+	// value is port record of the received message used to requeue it.
+	value interface{}
+}
+
+// Send replies to the call. It fails the test if the port doesn't
+// support replies to a specific call.
+func (c *Call) Send(t *testing.T, i interface{}, opts ...SendOption) error {
+	if c.port == nil || c.send == nil {
+		t.Fatalf("failed to send %T, received %T message doesn't support replies", i, c.Msg)
+		return nil
+	}
+	return c.port.send(t, c.send, i, opts...)
+}
+
+func (p *Port) receive(t *testing.T, ctx context.Context, opts ...ReceiveOption) (interface{}, error) {
+	if len(opts) == 0 {
+		return p.impl.Receive(ctx)
+	}
+	r, ok := p.impl.(selectiveReceiver)
+	if !ok {
+		t.Fatalf("%s doesn't support receive options", getPortName(p.impl))
+	}
+	var options receiveOptions
+	for _, o := range opts {
+		o(&options)
+	}
+	return r.receiveSelected(ctx, options)
+}
+
 func getPortName(i interface{}) string {
 	name := fmt.Sprintf("%T", i)
 	return fmt.Sprintf("%s", strings.ToLower(name))
 }
 
-func (p *Port) Receive(t *testing.T, i interface{}) (interface{}, error) {
+func (p *Port) Receive(t *testing.T, i interface{}, opts ...ReceiveOption) (*Call, error) {
 	ctx := context.Background()
-	m, err := p.impl.Receive(ctx)
-
-	name := getPortName(p.impl)
+	m, err := p.receive(t, ctx, opts...)
 
-	if matcher, ok := i.(*match.GRPCErrType); ok {
-		if mtfc := mtfctx.Get(t); mtfc != nil {
-			mtfc.LogReceive(name, err)
-		}
-		if err := matcher.Match(err); err != nil {
-			t.Fatalf("Failed to receive GRPC error: %v", err)
+	call, isCall := m.(*Call)
+	if !isCall {
+		call = &Call{
+			Msg: m,
 		}
-		return i, nil
 	}
+	call.port = p
+	m = call.Msg
 
+	name := getPortName(p.impl)
 	if mtfc := mtfctx.Get(t); mtfc != nil {
-		mtfc.LogReceive(name, m)
+		mtfc.LogReceive(name, errOrMsg(m, err))
 	}
 
-	if err != nil {
+	// Ports return call with error only for error responses.
+	if !isCall && err != nil {
 		t.Fatalf("failed to receive %T from %s: %v", i, name, err)
 	}
 
-	switch t := i.(type) {
-	case *match.FnType:
-		err = t.Match(err, m)
-	case *match.TypeT:
-		err = t.Match(m)
-	case *match.DeepEqualType:
-		err = t.Match(m)
-	case *match.PayloadMatcher:
-		err = t.Match(err, m)
-	case *match.ProtoEqualType:
-		err = t.Match(m)
-	case *match.DiffType:
-		err = t.Match(m)
-	default:
-		err = match.DeepEqual(i).Match(m)
+	matcher := receiveMatcher(i)
+	if err := matcher.MatchReceived(err, m); err != nil {
+		t.Fatalf("%s", matcher.FailureMessage(m, err))
 	}
 
-	if err != nil {
-		t.Fatalf("Failed to receive %T:\n %v", i, err)
+	return call, nil
+}
+
+// NoReceive fails the test if the port receives a message within d.
+// ReceiveOption limits the check to the selected messages.
+func (p *Port) NoReceive(t *testing.T, d time.Duration, opts ...ReceiveOption) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	for {
+		m, err := p.receive(t, ctx, opts...)
+		if call, ok := m.(*Call); ok {
+			m = call.Msg
+			if m == nil {
+				// Client port delivers error responses as call with error.
+				m = err
+			}
+		}
+		if m != nil {
+			if mtfc := mtfctx.Get(t); mtfc != nil {
+				mtfc.LogReceive(getPortName(p.impl), m)
+			}
+			t.Fatalf("Unexpected message %T received from %s: %v", m, getPortName(p.impl), m)
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
 	}
+}
 
-	return m, nil
+// receiveMatcher returns matcher of received message, i is either
+// a match.ReceiveMatcher or expected message compared by DeepEqual.
+func receiveMatcher(i interface{}) match.ReceiveMatcher {
+	if matcher, ok := i.(match.ReceiveMatcher); ok {
+		return matcher
+	}
+	if exp, ok := i.(*HTTPRequest); ok {
+		return &httpRequestMatcher{
+			exp: exp,
+		}
+	}
+	return match.DeepEqual(i)
 }
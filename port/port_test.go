@@ -0,0 +1,64 @@
+package port
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/smallinsky/mtf/proto/oracle"
+)
+
+// prefixMatcher is custom matcher of oracle request data prefix.
+type prefixMatcher struct {
+	prefix string
+}
+
+func (m *prefixMatcher) MatchReceived(err error, got interface{}) error {
+	if err != nil {
+		return err
+	}
+	req, ok := got.(*oracle.AskDeepThoughtRequest)
+	if !ok {
+		return fmt.Errorf("unexpected message %T", got)
+	}
+	if !strings.HasPrefix(req.GetData(), m.prefix) {
+		return fmt.Errorf("%q has no prefix %q", req.GetData(), m.prefix)
+	}
+	return nil
+}
+
+func (m *prefixMatcher) FailureMessage(got interface{}, err error) string {
+	return fmt.Sprintf("prefix %q not matched: %v", m.prefix, err)
+}
+
+func TestReceiveCustomMatcher(t *testing.T) {
+	svr, err := NewGRPCServerPort((*oracle.OracleServer)(nil), ":9982")
+	if err != nil {
+		t.Fatal("failed to create grpc server port: ", err)
+	}
+	client, err := NewGRPCClientPort((*oracle.OracleClient)(nil), "localhost:9982")
+	if err != nil {
+		t.Fatal("failed to create grpc client port: ", err)
+	}
+
+	svr.Stub(t, &prefixMatcher{prefix: "ping"}, &oracle.AskDeepThoughtResponse{
+		Data: "pong",
+	})
+	client.Send(t, &oracle.AskDeepThoughtRequest{
+		Data: "ping 1",
+	})
+	client.Receive(t, &oracle.AskDeepThoughtResponse{
+		Data: "pong",
+	})
+
+	client.Send(t, &oracle.AskDeepThoughtRequest{
+		Data: "Ultimate question",
+	})
+	svr.Receive(t, &prefixMatcher{prefix: "Ultimate"})
+	svr.Send(t, &oracle.AskDeepThoughtResponse{
+		Data: "42",
+	})
+	client.Receive(t, &oracle.AskDeepThoughtResponse{
+		Data: "42",
+	})
+}
@@ -0,0 +1,119 @@
+package port
+
+import (
+	"sync"
+	"testing"
+
+	mtfctx "github.com/smallinsky/mtf/framework/context"
+)
+
+// Stub is a port rule replying to every received message matching the
+// request without Receive and Send calls made by the test.
+type Stub struct {
+	req   interface{}
+	resp  interface{}
+	times int
+
+	set   *stubSet
+	calls int
+	// testScoped is set for stubs added by suite test method, they are
+	// removed when the port is verified after the method.
+	testScoped bool
+}
+
+type StubOption func(*Stub)
+
+// WithTimes limits the number of replies of the stub to n, further
+// matching messages are passed to Receive. Port Verify fails if the stub
+// replied less than n times.
+func WithTimes(n int) StubOption {
+	return func(s *Stub) {
+		s.times = n
+	}
+}
+
+// Calls returns the number of messages the stub replied to.
+func (s *Stub) Calls() int {
+	s.set.mtx.Lock()
+	defer s.set.mtx.Unlock()
+	return s.calls
+}
+
+// Remove removes the stub from the port, further matching messages are
+// passed to Receive and the stub is not checked by port Verify.
+func (s *Stub) Remove() {
+	s.set.remove(s)
+}
+
+// stubSet holds port stubs, ports check it for the reply before
+// passing received message to Receive.
+type stubSet struct {
+	mtx   sync.Mutex
+	stubs []*Stub
+}
+
+func newStubSet() *stubSet {
+	return &stubSet{}
+}
+
+func (s *stubSet) add(stub *Stub) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	stub.set = s
+	s.stubs = append(s.stubs, stub)
+}
+
+func (s *stubSet) remove(stub *Stub) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for i, st := range s.stubs {
+		if st == stub {
+			s.stubs = append(s.stubs[:i], s.stubs[i+1:]...)
+			return
+		}
+	}
+}
+
+// reply returns the response of the first stub matching msg.
+func (s *stubSet) reply(msg interface{}) (interface{}, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, stub := range s.stubs {
+		if stub.times > 0 && stub.calls >= stub.times {
+			continue
+		}
+		if err := receiveMatcher(stub.req).MatchReceived(nil, msg); err != nil {
+			continue
+		}
+		stub.calls++
+		return stub.resp, true
+	}
+	return nil, false
+}
+
+// stubber is implemented by ports replying to incoming messages.
+type stubber interface {
+	stubSet() *stubSet
+}
+
+// Stub replies with resp to messages matching req any number of times
+// or the number set by WithTimes option. req is expected message or
+// matcher accepted by Receive, not matching messages are still passed
+// to Receive. Stubs added by suite test method are removed after it.
+func (p *Port) Stub(t *testing.T, req, resp interface{}, opts ...StubOption) *Stub {
+	s, ok := p.impl.(stubber)
+	if !ok {
+		t.Fatalf("%s port doesn't support stubs", getPortName(p.impl))
+		return nil
+	}
+	stub := &Stub{
+		req:        req,
+		resp:       resp,
+		testScoped: mtfctx.Get(t) != nil,
+	}
+	for _, o := range opts {
+		o(stub)
+	}
+	s.stubSet().add(stub)
+	return stub
+}
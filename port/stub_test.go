@@ -0,0 +1,105 @@
+package port
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/smallinsky/mtf/proto/oracle"
+)
+
+func TestGRPCStub(t *testing.T) {
+	svr, err := NewGRPCServerPort((*oracle.OracleServer)(nil), ":9986")
+	if err != nil {
+		t.Fatal("failed to create grpc server port: ", err)
+	}
+	client, err := NewGRPCClientPort((*oracle.OracleClient)(nil), "localhost:9986")
+	if err != nil {
+		t.Fatal("failed to create grpc client port: ", err)
+	}
+
+	ping := svr.Stub(t, &oracle.AskDeepThoughtRequest{
+		Data: "ping",
+	}, &oracle.AskDeepThoughtResponse{
+		Data: "pong",
+	})
+	limited := svr.Stub(t, &oracle.AskDeepThoughtRequest{
+		Data: "limited",
+	}, &oracle.AskDeepThoughtResponse{
+		Data: "stub",
+	}, WithTimes(1))
+
+	for i := 0; i < 2; i++ {
+		client.Send(t, &oracle.AskDeepThoughtRequest{
+			Data: "ping",
+		})
+		client.Receive(t, &oracle.AskDeepThoughtResponse{
+			Data: "pong",
+		})
+	}
+	if got, exp := ping.Calls(), 2; got != exp {
+		t.Fatalf("Got: '%v' Expected: '%v'", got, exp)
+	}
+
+	client.Send(t, &oracle.AskDeepThoughtRequest{
+		Data: "limited",
+	})
+	client.Receive(t, &oracle.AskDeepThoughtResponse{
+		Data: "stub",
+	})
+
+	// Limit of the stub is reached, the call is passed to Receive.
+	client.Send(t, &oracle.AskDeepThoughtRequest{
+		Data: "limited",
+	})
+	svr.Receive(t, &oracle.AskDeepThoughtRequest{
+		Data: "limited",
+	})
+	svr.Send(t, &oracle.AskDeepThoughtResponse{
+		Data: "received",
+	})
+	client.Receive(t, &oracle.AskDeepThoughtResponse{
+		Data: "received",
+	})
+	if got, exp := limited.Calls(), 1; got != exp {
+		t.Fatalf("Got: '%v' Expected: '%v'", got, exp)
+	}
+
+	// Removed stub doesn't reply, the call is passed to Receive.
+	ping.Remove()
+	client.Send(t, &oracle.AskDeepThoughtRequest{
+		Data: "ping",
+	})
+	svr.Receive(t, &oracle.AskDeepThoughtRequest{
+		Data: "ping",
+	})
+	svr.Send(t, &oracle.AskDeepThoughtResponse{
+		Data: "received",
+	})
+	client.Receive(t, &oracle.AskDeepThoughtResponse{
+		Data: "received",
+	})
+}
+
+func TestHTTPServerStub(t *testing.T) {
+	genCerts(t)
+	port := NewHTTPPort(WithHost("stub.example.com"))
+	port.Stub(t, &HTTPRequest{
+		Method: http.MethodGet,
+		Host:   "stub.example.com",
+		URL:    "/health",
+	}, &HTTPResponse{
+		Status: http.StatusNoContent,
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost:8080/health", nil)
+	req.Host = "stub.example.com"
+	resp, err := (&http.Client{Timeout: time.Second}).Do(req)
+	if err != nil {
+		t.Fatalf("http call failed: %v", err)
+	}
+	resp.Body.Close()
+	if want, got := http.StatusNoContent, resp.StatusCode; want != got {
+		t.Fatalf("StatusCode want: %v, got %v", want, got)
+	}
+}
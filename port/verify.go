@@ -0,0 +1,210 @@
+package port
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// drainer is implemented by ports queueing messages for Receive, drain
+// removes messages not consumed by the test and returns them.
+type drainer interface {
+	drain() []interface{}
+}
+
+// Verify checks that all messages delivered to the port were received
+// by the test and that stubs limited by WithTimes replied the expected
+// number of times. Not received messages and stubs added by suite test
+// method are dropped, so they don't leak to the next test.
+func (p *Port) Verify() error {
+	return p.verify(true)
+}
+
+// VerifyTest is Verify run after suite test method, only stubs added by
+// the test method are checked. Other stubs replies add up across test
+// methods, so they are checked by Verify after all of them.
+func (p *Port) VerifyTest() error {
+	return p.verify(false)
+}
+
+func (p *Port) verify(allStubs bool) error {
+	var errs []string
+	d, ok := p.impl.(drainer)
+	if !ok {
+		return errors.Errorf("%s port doesn't support verification", getPortName(p.impl))
+	}
+	for _, msg := range d.drain() {
+		errs = append(errs, fmt.Sprintf("unexpected message %T: %v", msg, msg))
+	}
+	if s, ok := p.impl.(stubber); ok {
+		errs = append(errs, s.stubSet().verify(allStubs)...)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.Errorf("%s port: %s", getPortName(p.impl), strings.Join(errs, "\n"))
+}
+
+// verify checks replies of test scoped stubs or of all stubs and removes
+// test scoped ones.
+func (s *stubSet) verify(all bool) []string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	var (
+		errs  []string
+		stubs []*Stub
+	)
+	for _, stub := range s.stubs {
+		if (all || stub.testScoped) && stub.times > 0 && stub.calls != stub.times {
+			errs = append(errs, fmt.Sprintf("stub %T replied %d times, expected %d", stub.req, stub.calls, stub.times))
+		}
+		if !stub.testScoped {
+			stubs = append(stubs, stub)
+		}
+	}
+	s.stubs = stubs
+	return errs
+}
+
+// drain fails not received calls with Aborted code.
+func (p *PortIn) drain() []interface{} {
+	p.mtx.Lock()
+	values := p.pending
+	p.pending = nil
+	p.call = nil
+	p.mtx.Unlock()
+	for done := false; !done; {
+		select {
+		case v := <-p.reqC:
+			values = append(values, v)
+		default:
+			done = true
+		}
+	}
+
+	var msgs []interface{}
+	for _, v := range values {
+		go v.call.send(context.Background(), &GRPCErr{
+			Err: status.Error(codes.Aborted, "mtf: call not received by test"),
+		})
+		msgs = append(msgs, v.msg)
+	}
+	return msgs
+}
+
+// drain replies to not received requests with InternalServerError status.
+func (p *HTTPPort) drain() []interface{} {
+	p.mtx.Lock()
+	calls := p.pending
+	p.pending = nil
+	p.call = nil
+	p.mtx.Unlock()
+	for done := false; !done; {
+		select {
+		case call := <-p.reqC:
+			calls = append(calls, call)
+		default:
+			done = true
+		}
+	}
+
+	var msgs []interface{}
+	for _, call := range calls {
+		call.send(context.Background(), &HTTPResponse{
+			Status: http.StatusInternalServerError,
+			Body:   []byte("mtf: request not received by test"),
+		})
+		msgs = append(msgs, call.req)
+	}
+	return msgs
+}
+
+// drain replies to not received requests with empty responses.
+func (s *GCStorage) drain() []interface{} {
+	var msgs []interface{}
+	for m, ok := s.queue.pop(); ok; m, ok = s.queue.pop() {
+		msgs = append(msgs, m.msg)
+	}
+	for done := false; !done; {
+		select {
+		case msg := <-s.inEvent:
+			msgs = append(msgs, msg)
+		default:
+			done = true
+		}
+	}
+
+	for _, msg := range msgs {
+		var resp interface{} = &StorageInsertResponse{}
+		if _, ok := msg.(*StorageGetRequest); ok {
+			resp = &StorageGetResponse{}
+		}
+		go s.send(resp)
+	}
+	return msgs
+}
+
+func (p *ClientPort) drain() []interface{} {
+	var msgs []interface{}
+	for m, ok := p.queue.pop(); ok; m, ok = p.queue.pop() {
+		msgs = append(msgs, errOrMsg(callMsg(m.msg), m.err))
+	}
+	for {
+		select {
+		case result := <-p.callResultC:
+			if result.err != nil {
+				msgs = append(msgs, result.err)
+				continue
+			}
+			msgs = append(msgs, result.resp)
+		default:
+			return msgs
+		}
+	}
+}
+
+func (p *Pubsub) drain() []interface{} {
+	var msgs []interface{}
+	for m, ok := p.queue.pop(); ok; m, ok = p.queue.pop() {
+		msgs = append(msgs, m.msg)
+	}
+	for {
+		select {
+		case msg := <-p.messages:
+			msgs = append(msgs, msg)
+		default:
+			return msgs
+		}
+	}
+}
+
+func (p *FTPPort) drain() []interface{} {
+	var msgs []interface{}
+	for m, ok := p.queue.pop(); ok; m, ok = p.queue.pop() {
+		msgs = append(msgs, m.msg)
+	}
+	for {
+		select {
+		case msg := <-p.ftpEventC:
+			msgs = append(msgs, &FTPEvent{
+				Path:    msg.GetPath(),
+				Payload: msg.GetContent(),
+			})
+		default:
+			return msgs
+		}
+	}
+}
+
+// callMsg returns message of received *Call or m itself.
+func callMsg(m interface{}) interface{} {
+	if call, ok := m.(*Call); ok {
+		return call.Msg
+	}
+	return m
+}
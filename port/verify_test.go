@@ -0,0 +1,130 @@
+package port
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+
+	"github.com/smallinsky/mtf/match"
+	"github.com/smallinsky/mtf/proto/oracle"
+)
+
+func TestVerify(t *testing.T) {
+	svr, err := NewGRPCServerPort((*oracle.OracleServer)(nil), ":9985")
+	if err != nil {
+		t.Fatal("failed to create grpc server port: ", err)
+	}
+	client, err := NewGRPCClientPort((*oracle.OracleClient)(nil), "localhost:9985")
+	if err != nil {
+		t.Fatal("failed to create grpc client port: ", err)
+	}
+
+	t.Run("NotReceivedCall", func(t *testing.T) {
+		client.Send(t, &oracle.AskDeepThoughtRequest{
+			Data: "Ultimate question",
+		})
+		// Wait for the call to reach the server port.
+		time.Sleep(100 * time.Millisecond)
+
+		err := svr.Verify()
+		if err == nil || !strings.Contains(err.Error(), "Ultimate question") {
+			t.Fatalf("Got: '%v' Expected unexpected message error", err)
+		}
+		client.Receive(t, match.GRPCStatusCode(codes.Aborted))
+		if err := svr.Verify(); err != nil {
+			t.Fatalf("Got: '%v' Expected: nil", err)
+		}
+		if err := client.Verify(); err != nil {
+			t.Fatalf("Got: '%v' Expected: nil", err)
+		}
+	})
+
+	t.Run("StubCalls", func(t *testing.T) {
+		svr.Stub(t, &oracle.AskDeepThoughtRequest{
+			Data: "ping",
+		}, &oracle.AskDeepThoughtResponse{
+			Data: "pong",
+		}, WithTimes(2))
+		client.Send(t, &oracle.AskDeepThoughtRequest{
+			Data: "ping",
+		})
+		client.Receive(t, &oracle.AskDeepThoughtResponse{
+			Data: "pong",
+		})
+		if err := svr.Verify(); err == nil || !strings.Contains(err.Error(), "replied 1 times, expected 2") {
+			t.Fatalf("Got: '%v' Expected stub calls error", err)
+		}
+	})
+
+	t.Run("NotReceivedPubsubMessage", func(t *testing.T) {
+		ps := &Pubsub{
+			messages: make(chan proto.Message, 1),
+		}
+		ps.messages <- &oracle.AskDeepThoughtRequest{Data: "published"}
+		p := &Port{impl: ps}
+		if err := p.Verify(); err == nil || !strings.Contains(err.Error(), "published") {
+			t.Fatalf("Got: '%v' Expected unexpected message error", err)
+		}
+		if err := p.Verify(); err != nil {
+			t.Fatalf("Got: '%v' Expected: nil", err)
+		}
+	})
+
+	t.Run("NotVerifiablePort", func(t *testing.T) {
+		p := &Port{impl: &barrierPort{}}
+		if err := p.Verify(); err == nil || !strings.Contains(err.Error(), "doesn't support verification") {
+			t.Fatalf("Got: '%v' Expected verification error", err)
+		}
+	})
+}
+
+func TestNoReceive(t *testing.T) {
+	svr, err := NewGRPCServerPort((*oracle.OracleServer)(nil), ":9984")
+	if err != nil {
+		t.Fatal("failed to create grpc server port: ", err)
+	}
+	client, err := NewGRPCClientPort((*oracle.OracleClient)(nil), "localhost:9984")
+	if err != nil {
+		t.Fatal("failed to create grpc client port: ", err)
+	}
+
+	start := time.Now()
+	svr.NoReceive(t, 200*time.Millisecond)
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("NoReceive returned after %v Expected at least 200ms", elapsed)
+	}
+
+	// Message sent after NoReceive window is still delivered to Receive.
+	client.Send(t, &oracle.AskDeepThoughtRequest{
+		Data: "Ultimate question",
+	})
+	svr.Receive(t, &oracle.AskDeepThoughtRequest{
+		Data: "Ultimate question",
+	})
+	svr.Send(t, &oracle.AskDeepThoughtResponse{
+		Data: "42",
+	})
+	client.Receive(t, &oracle.AskDeepThoughtResponse{
+		Data: "42",
+	})
+	client.NoReceive(t, 100*time.Millisecond)
+}
+
+func TestPorts(t *testing.T) {
+	impl := &barrierPort{}
+	newPort(impl)
+	newPort(impl)
+
+	var n int
+	for _, p := range Ports() {
+		if p.impl == impl {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Fatalf("Got: '%v' Expected: '%v'", n, 1)
+	}
+}
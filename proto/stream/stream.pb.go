@@ -0,0 +1,369 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: proto/stream/stream.proto
+
+package stream
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type StreamRequest struct {
+	Data                 string   `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StreamRequest) Reset()         { *m = StreamRequest{} }
+func (m *StreamRequest) String() string { return proto.CompactTextString(m) }
+func (*StreamRequest) ProtoMessage()    {}
+func (*StreamRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1b2373f7d4156930, []int{0}
+}
+
+func (m *StreamRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StreamRequest.Unmarshal(m, b)
+}
+func (m *StreamRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StreamRequest.Marshal(b, m, deterministic)
+}
+func (m *StreamRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StreamRequest.Merge(m, src)
+}
+func (m *StreamRequest) XXX_Size() int {
+	return xxx_messageInfo_StreamRequest.Size(m)
+}
+func (m *StreamRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_StreamRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StreamRequest proto.InternalMessageInfo
+
+func (m *StreamRequest) GetData() string {
+	if m != nil {
+		return m.Data
+	}
+	return ""
+}
+
+type StreamResponse struct {
+	Data                 string   `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StreamResponse) Reset()         { *m = StreamResponse{} }
+func (m *StreamResponse) String() string { return proto.CompactTextString(m) }
+func (*StreamResponse) ProtoMessage()    {}
+func (*StreamResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1b2373f7d4156930, []int{1}
+}
+
+func (m *StreamResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StreamResponse.Unmarshal(m, b)
+}
+func (m *StreamResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StreamResponse.Marshal(b, m, deterministic)
+}
+func (m *StreamResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StreamResponse.Merge(m, src)
+}
+func (m *StreamResponse) XXX_Size() int {
+	return xxx_messageInfo_StreamResponse.Size(m)
+}
+func (m *StreamResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_StreamResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StreamResponse proto.InternalMessageInfo
+
+func (m *StreamResponse) GetData() string {
+	if m != nil {
+		return m.Data
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*StreamRequest)(nil), "stream.StreamRequest")
+	proto.RegisterType((*StreamResponse)(nil), "stream.StreamResponse")
+}
+
+func init() { proto.RegisterFile("proto/stream/stream.proto", fileDescriptor_1b2373f7d4156930) }
+
+var fileDescriptor_1b2373f7d4156930 = []byte{
+	// 155 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x92, 0x2c, 0x28, 0xca, 0x2f,
+	0xc9, 0xd7, 0x2f, 0x2e, 0x29, 0x4a, 0x4d, 0xcc, 0x85, 0x52, 0x7a, 0x60, 0x31, 0x21, 0x36, 0x08,
+	0x4f, 0x49, 0x99, 0x8b, 0x37, 0x18, 0xcc, 0x0a, 0x4a, 0x2d, 0x2c, 0x4d, 0x2d, 0x2e, 0x11, 0x12,
+	0xe2, 0x62, 0x49, 0x49, 0x2c, 0x49, 0x94, 0x60, 0x54, 0x60, 0xd4, 0xe0, 0x0c, 0x02, 0xb3, 0x95,
+	0x54, 0xb8, 0xf8, 0x60, 0x8a, 0x8a, 0x0b, 0xf2, 0xf3, 0x8a, 0x53, 0xb1, 0xa9, 0x32, 0xba, 0xcc,
+	0xc8, 0xc5, 0x01, 0x51, 0x96, 0x5a, 0x24, 0xe4, 0xc8, 0xc5, 0x13, 0x9c, 0x5a, 0x54, 0x96, 0x5a,
+	0x04, 0x11, 0x11, 0x12, 0xd5, 0x83, 0x5a, 0x8f, 0x62, 0x9b, 0x94, 0x18, 0xba, 0x30, 0xc4, 0x7c,
+	0x25, 0x06, 0x03, 0x46, 0x90, 0x11, 0xce, 0x39, 0x99, 0xa9, 0x79, 0x25, 0x64, 0x1a, 0xa1, 0x01,
+	0x32, 0x82, 0xcb, 0x29, 0x33, 0x25, 0x93, 0x6c, 0x03, 0x0c, 0x18, 0x93, 0xd8, 0xc0, 0xe1, 0x65,
+	0x0c, 0x18, 0x00, 0x2a, 0x1a, 0x49, 0xda, 0x4c, 0x01, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// StreamerClient is the client API for Streamer service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type StreamerClient interface {
+	ServerStream(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (Streamer_ServerStreamClient, error)
+	ClientStream(ctx context.Context, opts ...grpc.CallOption) (Streamer_ClientStreamClient, error)
+	BidiStream(ctx context.Context, opts ...grpc.CallOption) (Streamer_BidiStreamClient, error)
+}
+
+type streamerClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewStreamerClient(cc *grpc.ClientConn) StreamerClient {
+	return &streamerClient{cc}
+}
+
+func (c *streamerClient) ServerStream(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (Streamer_ServerStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Streamer_serviceDesc.Streams[0], "/stream.Streamer/ServerStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &streamerServerStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Streamer_ServerStreamClient interface {
+	Recv() (*StreamResponse, error)
+	grpc.ClientStream
+}
+
+type streamerServerStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *streamerServerStreamClient) Recv() (*StreamResponse, error) {
+	m := new(StreamResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *streamerClient) ClientStream(ctx context.Context, opts ...grpc.CallOption) (Streamer_ClientStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Streamer_serviceDesc.Streams[1], "/stream.Streamer/ClientStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &streamerClientStreamClient{stream}
+	return x, nil
+}
+
+type Streamer_ClientStreamClient interface {
+	Send(*StreamRequest) error
+	CloseAndRecv() (*StreamResponse, error)
+	grpc.ClientStream
+}
+
+type streamerClientStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *streamerClientStreamClient) Send(m *StreamRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *streamerClientStreamClient) CloseAndRecv() (*StreamResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(StreamResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *streamerClient) BidiStream(ctx context.Context, opts ...grpc.CallOption) (Streamer_BidiStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Streamer_serviceDesc.Streams[2], "/stream.Streamer/BidiStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &streamerBidiStreamClient{stream}
+	return x, nil
+}
+
+type Streamer_BidiStreamClient interface {
+	Send(*StreamRequest) error
+	Recv() (*StreamResponse, error)
+	grpc.ClientStream
+}
+
+type streamerBidiStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *streamerBidiStreamClient) Send(m *StreamRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *streamerBidiStreamClient) Recv() (*StreamResponse, error) {
+	m := new(StreamResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// StreamerServer is the server API for Streamer service.
+type StreamerServer interface {
+	ServerStream(*StreamRequest, Streamer_ServerStreamServer) error
+	ClientStream(Streamer_ClientStreamServer) error
+	BidiStream(Streamer_BidiStreamServer) error
+}
+
+// UnimplementedStreamerServer can be embedded to have forward compatible implementations.
+type UnimplementedStreamerServer struct {
+}
+
+func (*UnimplementedStreamerServer) ServerStream(req *StreamRequest, srv Streamer_ServerStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method ServerStream not implemented")
+}
+func (*UnimplementedStreamerServer) ClientStream(srv Streamer_ClientStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method ClientStream not implemented")
+}
+func (*UnimplementedStreamerServer) BidiStream(srv Streamer_BidiStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method BidiStream not implemented")
+}
+
+func RegisterStreamerServer(s *grpc.Server, srv StreamerServer) {
+	s.RegisterService(&_Streamer_serviceDesc, srv)
+}
+
+func _Streamer_ServerStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StreamerServer).ServerStream(m, &streamerServerStreamServer{stream})
+}
+
+type Streamer_ServerStreamServer interface {
+	Send(*StreamResponse) error
+	grpc.ServerStream
+}
+
+type streamerServerStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *streamerServerStreamServer) Send(m *StreamResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Streamer_ClientStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(StreamerServer).ClientStream(&streamerClientStreamServer{stream})
+}
+
+type Streamer_ClientStreamServer interface {
+	SendAndClose(*StreamResponse) error
+	Recv() (*StreamRequest, error)
+	grpc.ServerStream
+}
+
+type streamerClientStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *streamerClientStreamServer) SendAndClose(m *StreamResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *streamerClientStreamServer) Recv() (*StreamRequest, error) {
+	m := new(StreamRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Streamer_BidiStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(StreamerServer).BidiStream(&streamerBidiStreamServer{stream})
+}
+
+type Streamer_BidiStreamServer interface {
+	Send(*StreamResponse) error
+	Recv() (*StreamRequest, error)
+	grpc.ServerStream
+}
+
+type streamerBidiStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *streamerBidiStreamServer) Send(m *StreamResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *streamerBidiStreamServer) Recv() (*StreamRequest, error) {
+	m := new(StreamRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var _Streamer_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "stream.Streamer",
+	HandlerType: (*StreamerServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ServerStream",
+			Handler:       _Streamer_ServerStream_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ClientStream",
+			Handler:       _Streamer_ClientStream_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "BidiStream",
+			Handler:       _Streamer_BidiStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "proto/stream/stream.proto",
+}